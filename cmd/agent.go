@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/spf13/cobra"
+)
+
+// agentCmd 是网关代理模式，由客户端上传到网关节点后通过SSH通道启动
+var agentCmd = &cobra.Command{
+	Use:    "agent",
+	Short:  "网关代理模式（内部使用）",
+	Hidden: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := session.ServeAgent(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "agent: %v\n", err)
+			os.Exit(1)
+		}
+	},
+}
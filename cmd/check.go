@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/logic/health"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	checkTimeout  int
+	checkFanout   int
+	checkDialRate float64
+)
+
+// checkCmd 重新检查节点的可用性
+var checkCmd = &cobra.Command{
+	Use:   "check [group-name...]",
+	Short: "检查节点可用性",
+	Long: `依次检查节点的 TCP 可达、SSH 认证和 shell 启动并记录耗时，更新节点的可用状态。
+检查结果保存为历史，group show 据此显示可用率和抖动的节点。不指定组时检查所有组，
+也可以使用 daemon --check-every 定期检查。`,
+	Example: `  talko check
+  talko check web db -t 5`,
+	Run: checkFunc,
+}
+
+func init() {
+	checkCmd.Flags().IntVarP(&checkTimeout, "timeout", "t", 10, "每个阶段的超时时间（秒）")
+	checkCmd.Flags().IntVar(&checkFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	checkCmd.Flags().Float64Var(&checkDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+}
+
+func checkFunc(cmd *cobra.Command, args []string) {
+	results, err := health.CheckAll(args, checkFanout, checkDialRate, time.Duration(checkTimeout)*time.Second)
+	if err != nil {
+		color.Red("Check failed: %v", err)
+		return
+	}
+	if len(results) == 0 {
+		fmt.Println("No groups found")
+		return
+	}
+
+	for _, result := range results {
+		color.Cyan("Group: %s", result.Group.Name)
+		if result.Err != nil {
+			color.Red("  %v", result.Err)
+		}
+
+		usable := 0
+		for _, check := range result.Checks {
+			if !check.Usable {
+				color.Red("  ✗ %-15s %s", check.NodeIP, check.Error)
+				continue
+			}
+			usable++
+			color.Green("  ✓ %-15s tcp %dms, ssh %dms, shell %dms", check.NodeIP, check.TCPLatency, check.SSHLatency, check.ShellLatency)
+		}
+		fmt.Printf("  %d/%d nodes usable\n\n", usable, len(result.Checks))
+	}
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/logic/metrics"
+	"zhaowanpeng/cluster-manager/internal/logic/schedule"
+	"zhaowanpeng/cluster-manager/internal/utils"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonCheckEvery   string
+	daemonCheckTimeout int
+	daemonListen       string
+)
+
+// daemonCmd 常驻运行定时任务
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "常驻运行定时任务",
+	Long: `前台常驻运行，按计划执行 schedule 添加的定时任务，指定 --check-every 时同时定期检查所有节点（见 check）。
+指定 --listen 时在该地址的 /metrics 以 Prometheus 格式提供节点可用性、SSH 握手耗时、命令执行次数和定时任务结果。
+收到 SIGINT 或 SIGTERM 后等待正在执行的任务完成再退出。`,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		daemon := schedule.NewDaemon()
+		if daemonCheckEvery != "" {
+			every, err := utils.ParseDuration(daemonCheckEvery)
+			if err != nil {
+				color.Red("Parse --check-every failed: %v", err)
+				return
+			}
+			daemon.SetHealthCheck(every, time.Duration(daemonCheckTimeout)*time.Second)
+		}
+
+		if daemonListen != "" {
+			listener, err := net.Listen("tcp", daemonListen)
+			if err != nil {
+				color.Red("Listen on %s failed: %v", daemonListen, err)
+				return
+			}
+			go func() {
+				if err := metrics.Serve(ctx, listener); err != nil {
+					color.Red("Metrics server failed: %v", err)
+				}
+			}()
+			color.Cyan("Serving metrics on http://%s/metrics", listener.Addr())
+		}
+
+		color.Cyan("Daemon started, press Ctrl+C to stop")
+		if err := daemon.Serve(ctx); err != nil {
+			color.Red("Daemon failed: %v", err)
+			return
+		}
+		color.Cyan("Daemon stopped")
+	},
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonCheckEvery, "check-every", "", "定期检查所有节点的间隔，如 5m，不指定时不检查")
+	daemonCmd.Flags().IntVar(&daemonCheckTimeout, "check-timeout", 10, "节点检查每个阶段的超时时间（秒）")
+	daemonCmd.Flags().StringVar(&daemonListen, "listen", "", "提供 Prometheus 指标的地址，如 :9273，不指定时不提供")
+}
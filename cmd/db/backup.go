@@ -0,0 +1,37 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup [file]",
+	Short: "在线备份数据库",
+	Long:  "生成数据库的一致性快照，未指定文件时备份到当前目录下的 cluster-<时间>.db",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   dbBackupFunc,
+}
+
+func dbBackupFunc(cmd *cobra.Command, args []string) {
+	dest := fmt.Sprintf("cluster-%s.db", time.Now().Format("20060102150405"))
+	if len(args) > 0 {
+		dest = args[0]
+	}
+
+	if err := model.BackupDB(dest); err != nil {
+		color.Red("Backup failed: %v", err)
+		return
+	}
+
+	size := int64(0)
+	if info, err := os.Stat(dest); err == nil {
+		size = info.Size()
+	}
+	color.Green("Database backed up to %s (%d bytes)", dest, size)
+}
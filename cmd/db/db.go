@@ -0,0 +1,16 @@
+package db
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var DBCmd = &cobra.Command{
+	Use:   "db",
+	Short: "数据库管理",
+	Long:  "数据库管理，包括在线备份和恢复",
+}
+
+func init() {
+	DBCmd.AddCommand(dbBackupCmd)
+	DBCmd.AddCommand(dbRestoreCmd)
+}
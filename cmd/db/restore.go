@@ -0,0 +1,52 @@
+package db
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var dbRestoreForce bool
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "从备份恢复数据库",
+	Long:  "校验备份文件后替换当前数据库，原数据库保留为 cluster.db.before-restore",
+	Args:  cobra.ExactArgs(1),
+	Run:   dbRestoreFunc,
+}
+
+func init() {
+	dbRestoreCmd.Flags().BoolVarP(&dbRestoreForce, "force", "f", false, "不确认直接恢复")
+}
+
+func dbRestoreFunc(cmd *cobra.Command, args []string) {
+	src := args[0]
+
+	// 确认恢复
+	if !dbRestoreForce {
+		fmt.Printf("Current database will be replaced by '%s'. Continue? [y/N]: ", src)
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			color.Red("Read input failed: %v", err)
+			return
+		}
+		input = strings.ToLower(strings.TrimSpace(input))
+		if input != "y" && input != "yes" {
+			fmt.Println("Operation cancelled")
+			return
+		}
+	}
+
+	if err := model.RestoreDB(src); err != nil {
+		color.Red("Restore failed: %v", err)
+		return
+	}
+
+	color.Green("Database restored from %s", src)
+}
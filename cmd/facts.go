@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/facts"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/types"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	factsTimeout  int
+	factsFanout   int
+	factsDialRate float64
+	factsJSON     bool
+)
+
+// factsCmd 采集节点的系统信息
+var factsCmd = &cobra.Command{
+	Use:   "facts [group-name...]",
+	Short: "采集节点系统信息",
+	Long: `采集节点的发行版、内核、主机名、CPU 数、内存、磁盘、IP、运行时长和虚拟化类型并保存到节点。
+保存的信息可以用 group show --facts 查看，也可以在 group exec --where 和 group show --where 中筛选节点，
+如 os_id=ubuntu,cpus>=8,memory>=16G,kernel>=5.15,virt=kvm。不指定组时采集所有组。`,
+	Example: `  talko facts web
+  talko facts --json`,
+	Run: factsFunc,
+}
+
+func init() {
+	factsCmd.Flags().IntVarP(&factsTimeout, "timeout", "t", 30, "每个节点的超时时间（秒）")
+	factsCmd.Flags().IntVar(&factsFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	factsCmd.Flags().Float64Var(&factsDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+	factsCmd.Flags().BoolVar(&factsJSON, "json", false, "以 JSON 输出采集结果")
+}
+
+func factsFunc(cmd *cobra.Command, args []string) {
+	var groups []model.Group
+	if len(args) == 0 {
+		var err error
+		if groups, err = crud.ListGroups(); err != nil {
+			color.Red("List groups failed: %v", err)
+			return
+		}
+	}
+	for _, name := range args {
+		group, err := crud.GetGroup(name)
+		if err != nil {
+			color.Red("Get group info failed: %v", err)
+			return
+		}
+		groups = append(groups, group)
+	}
+
+	var all []types.Result
+	for _, group := range groups {
+		nodes, err := crud.GetNodesInGroup(group.Name)
+		if err != nil {
+			color.Red("Get nodes info failed: %v", err)
+			return
+		}
+
+		results := gatherFacts(group, nodes)
+		if factsJSON {
+			all = append(all, results...)
+			continue
+		}
+
+		color.Cyan("Group: %s", group.Name)
+		for _, result := range results {
+			if !result.Success {
+				color.Red("  ✗ %-15s %s", result.IP, result.Msg)
+				continue
+			}
+			f := result.Data.(types.Facts)
+			color.Green("  ✓ %-15s %s", result.IP, f.Hostname)
+			fmt.Printf("    %s\n", result.Msg)
+		}
+		fmt.Println()
+	}
+
+	if factsJSON {
+		data, _ := json.MarshalIndent(all, "", "  ")
+		fmt.Println(string(data))
+	}
+}
+
+// gatherFacts 在组的节点上并发执行采集脚本并保存结果，结果的 Data 为采集到的 types.Facts
+func gatherFacts(group model.Group, nodes []model.Node) []types.Result {
+	sessionManager := session.NewSessionManager()
+	sessionManager.SetLimiter(fanout.ForGroup(group, factsFanout, factsDialRate))
+	defer sessionManager.CloseAll()
+
+	execResults := sessionManager.RunCommand(nodes, facts.Script, time.Duration(factsTimeout)*time.Second)
+
+	results := make([]types.Result, len(nodes))
+	for i, node := range nodes {
+		result := types.Result{IP: node.IP}
+		execResult := execResults[node.IP]
+		if !execResult.Success {
+			err := execResult.Error
+			if err == nil {
+				err = session.ExitCodeError(execResult.ExitCode)
+			}
+			result.Msg = fmt.Sprintf("采集失败: %v", err)
+			results[i] = result
+			continue
+		}
+
+		f, err := facts.Save(node, execResult.Output)
+		if err != nil {
+			result.Msg = err.Error()
+		} else {
+			result.Success = true
+			result.Msg = facts.Summary(f)
+		}
+		result.Data = f
+		results[i] = result
+	}
+	return results
+}
@@ -7,7 +7,9 @@ import (
 	"strings"
 	"syscall"
 	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
 	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+	"zhaowanpeng/cluster-manager/model"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -21,6 +23,9 @@ var (
 	groupUser        string
 	groupPassword    bool
 	groupDescription string
+	groupJump        string
+	groupFanout      int
+	groupDialRate    float64
 )
 var groupCreateCmd = &cobra.Command{
 	Use:   "create",
@@ -35,6 +40,9 @@ func init() {
 	groupCreateCmd.Flags().StringVarP(&groupUser, "user", "u", "root", "用户名")
 	groupCreateCmd.Flags().BoolVarP(&groupPassword, "password", "P", false, "是否使用密码")
 	groupCreateCmd.Flags().StringVarP(&groupDescription, "description", "d", "", "组描述")
+	groupCreateCmd.Flags().StringVar(&groupJump, "jump", "", "跳板机列表，逗号分隔的已保存节点ID或IP")
+	groupCreateCmd.Flags().IntVar(&groupFanout, "fanout", 0, "并发处理的节点数上限，0 表示使用默认值")
+	groupCreateCmd.Flags().Float64Var(&groupDialRate, "rate", 0, "每秒新建连接数上限，0 表示使用默认值")
 }
 
 func groupCreateFunc(cmd *cobra.Command, args []string) {
@@ -150,6 +158,16 @@ func groupCreateFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// 解析跳板机
+	jumpHosts := ""
+	if groupJump != "" {
+		jumpHosts, err = resolveJumpHosts(groupJump)
+		if err != nil {
+			color.Red("%v", err)
+			return
+		}
+	}
+
 	// 创建组
 	err = crud.AddGroup(groupName, groupDescription, "default", false)
 	if err != nil {
@@ -157,10 +175,27 @@ func groupCreateFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// 跳板机需在验证连接前设置
+	if jumpHosts != "" {
+		if err := crud.SetGroupJumpHosts(groupName, jumpHosts); err != nil {
+			color.Red("Set jump hosts failed: %v", err)
+			return
+		}
+	}
+
+	// 保存组的并发限制，之后对该组的所有操作都会沿用
+	if groupFanout > 0 || groupDialRate > 0 {
+		if err := crud.SetGroupLimits(groupName, groupFanout, groupDialRate); err != nil {
+			color.Red("Set group limits failed: %v", err)
+			return
+		}
+	}
+	limiter := fanout.ForGroup(model.Group{}, groupFanout, groupDialRate)
+
 	// 显示结果
 	fmt.Println("Verifying connection...")
 	// 添加节点到组
-	results, err := crud.AddOrUpdateNodes(groupName, ips, groupPort, groupUser, password, groupDescription)
+	results, err := crud.AddOrUpdateNodes(groupName, ips, groupPort, groupUser, password, groupDescription, limiter)
 	if err != nil {
 		color.Red("Add nodes to group failed: %v", err)
 		return
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/logic/jobs"
 	"zhaowanpeng/cluster-manager/internal/session"
 	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
 
@@ -24,6 +26,23 @@ var (
 	execPort         int
 	execUser         string
 	execPassword     string
+	execCompress     int
+	execGateway      bool
+	execFanout       int
+	execDialRate     float64
+	execBatchSize    int
+	execBatchPercent int
+	execPause        time.Duration
+	execHealthCheck  string
+	execCanary       int
+	execMaxFailures  string
+	execNoPTY        bool
+	execOutput       string
+	execNormalize    bool
+	execDiff         bool
+	execSort         string
+	execDetach       string
+	execWhere        string
 )
 
 var groupExecCmd = &cobra.Command{
@@ -41,7 +60,24 @@ func init() {
 	groupExecCmd.Flags().IntVarP(&execTimeout, "timeout", "t", 60, "命令执行超时时间（秒）")
 	groupExecCmd.Flags().StringVarP(&execExcludeNodes, "exclude", "e", "", "排除节点，支持范围表示法，如 192.168.1.1-5,192.168.1.10")
 	groupExecCmd.Flags().StringVarP(&execAddNodes, "add", "a", "", "额外添加节点，支持范围表示法")
+	groupExecCmd.Flags().StringVarP(&execWhere, "where", "W", "", "按节点系统信息筛选，如 os_id=ubuntu,cpus>=8,memory>=16G（见 facts）")
 	groupExecCmd.Flags().BoolVarP(&execMergeOutput, "merge", "m", false, "合并相同输出")
+	groupExecCmd.Flags().BoolVarP(&execGateway, "gateway", "G", false, "经子网网关执行（见 group gateway）")
+	groupExecCmd.Flags().IntVar(&execFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	groupExecCmd.Flags().Float64Var(&execDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+	groupExecCmd.Flags().StringVar(&execSort, "sort", session.SortIP, "结果排序方式: ip、duration 或 exit-code")
+	groupExecCmd.Flags().BoolVar(&execDiff, "diff", false, "以多数节点的输出为基准，只显示其余节点与基准的差异")
+	groupExecCmd.Flags().BoolVar(&execNormalize, "normalize", false, "合并输出时屏蔽IP、主机名、数字、时间戳和UUID等差异后再分组（隐含 -m）")
+	groupExecCmd.Flags().StringVarP(&execOutput, "output", "o", session.FormatText, "结果显示格式: text 或 json")
+	groupExecCmd.Flags().BoolVar(&execNoPTY, "no-pty", false, "不分配伪终端，分别捕获标准输出和标准错误（命令之间不保留工作目录）")
+	groupExecCmd.Flags().IntVar(&execBatchSize, "batch-size", 0, "滚动执行，每批节点数")
+	groupExecCmd.Flags().IntVar(&execBatchPercent, "batch-percent", 0, "滚动执行，每批节点占总数的百分比")
+	groupExecCmd.Flags().DurationVar(&execPause, "pause", 0, "批次之间的等待时间，如 10s")
+	groupExecCmd.Flags().StringVar(&execHealthCheck, "health-check", "", "每批执行后在该批节点上运行的健康检查命令，失败计入失败数")
+	groupExecCmd.Flags().IntVar(&execCanary, "canary", 0, "先在 K 个节点上执行，确认后再继续")
+	groupExecCmd.Flags().StringVar(&execMaxFailures, "max-failures", "", "累计失败超过该值时中止后续批次，支持数量或百分比，如 3 或 10%，0 表示出现失败即中止")
+	groupExecCmd.Flags().StringVar(&execDetach, "detach", "", "在后台启动命令并立即返回作业ID，用 jobs 命令查看状态和输出")
+	groupExecCmd.Flags().IntVar(&execCompress, "compress-threshold", 0, "记录输出超过该字节数时压缩存储，0 表示不压缩")
 	// groupExecCmd.Flags().IntVarP(&execPort, "port", "p", 22, "SSH端口（用于额外添加的节点）")
 	// groupExecCmd.Flags().StringVarP(&execUser, "user", "u", "root", "SSH用户名（用于额外添加的节点）")
 	// groupExecCmd.Flags().StringVarP(&execPassword, "password", "P", "", "SSH密码（用于额外添加的节点）")
@@ -103,6 +139,24 @@ func execFunc(cmd *cobra.Command, args []string) {
 
 	}
 
+	maxFailures, maxFailurePercent, err := session.ParseMaxFailures(execMaxFailures)
+	if err != nil {
+		color.Red("%v", err)
+		return
+	}
+	if execOutput != session.FormatText && execOutput != session.FormatJSON {
+		color.Red("不支持的输出格式: %s", execOutput)
+		return
+	}
+	if !session.ValidSort(execSort) {
+		color.Red("不支持的排序方式: %s", execSort)
+		return
+	}
+	if execBatchPercent < 0 || execBatchPercent > 100 {
+		color.Red("无效的批次百分比: %d", execBatchPercent)
+		return
+	}
+
 	// 提示用户设置的超时时间
 	color.Cyan("命令执行超时设置为 %d 秒", execTimeout)
 
@@ -112,16 +166,75 @@ func execFunc(cmd *cobra.Command, args []string) {
 		Timeout:      time.Duration(execTimeout) * time.Second,
 		ExcludeNodes: execExcludeNodes,
 		AddNodes:     execAddNodes,
-		MergeOutput:  execMergeOutput,
+		MergeOutput:  execMergeOutput || execNormalize,
 		Port:         execPort,
 		User:         execUser,
 		Password:     execPassword,
+
+		CompressThreshold: execCompress,
+		UseGateways:       execGateway,
+		Fanout:            execFanout,
+		DialRate:          execDialRate,
+		NoPTY:             execNoPTY,
+		OutputFormat:      execOutput,
+		Normalize:         execNormalize,
+		DiffOutput:        execDiff,
+		SortBy:            execSort,
+		Where:             execWhere,
+		Strategy: session.Strategy{
+			BatchSize:         execBatchSize,
+			BatchPercent:      execBatchPercent,
+			Pause:             execPause,
+			HealthCheck:       execHealthCheck,
+			Canary:            execCanary,
+			MaxFailures:       maxFailures,
+			MaxFailurePercent: maxFailurePercent,
+		},
+	}
+
+	if execDetach != "" {
+		detach(options, execDetach)
+		return
 	}
 
 	// 启动组执行会话
-	err := session.StartGroupExec(options)
+	err = session.StartGroupExec(options)
 	if err != nil {
 		color.Red("执行失败: %v", err)
 		return
 	}
 }
+
+// detach 在组内节点上后台启动命令，不进入交互式会话
+func detach(options session.ExecOptions, command string) {
+	group, nodes, err := session.ResolveNodes(options)
+	if err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	limiter := fanout.ForGroup(group, options.Fanout, options.DialRate)
+	job, results, err := jobs.Start(group.Name, nodes, command, limiter, options.Timeout)
+	if err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	var failed []string
+	for _, result := range results {
+		if !result.Success {
+			failed = append(failed, result.IP)
+			color.Red("[%s] %s", result.IP, result.Msg)
+		}
+	}
+	if len(failed) == len(results) {
+		color.Red("作业 %s 没有在任何节点上启动", job.ID)
+		return
+	}
+
+	color.Green("作业 %s 已在 %d 个节点上启动", job.ID, len(results)-len(failed))
+	if len(failed) > 0 {
+		color.Yellow("启动失败的节点: %s", session.CompressIPList(failed))
+	}
+	fmt.Printf("使用 jobs status %s 查看状态，jobs output %s 查看输出\n", job.ID, job.ID)
+}
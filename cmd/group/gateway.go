@@ -0,0 +1,139 @@
+package group
+
+import (
+	"fmt"
+	"sort"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	groupGatewayName   string
+	groupGatewaySubnet string
+	groupGatewayNode   string
+	groupGatewayAuto   bool
+	groupGatewayRemove bool
+	groupGatewayCreds  bool
+)
+
+var groupGatewayCmd = &cobra.Command{
+	Use:   "gateway [group-name]",
+	Short: "管理子网网关",
+	Long: `为组内的子网指定网关节点。使用 exec --gateway 时，程序会被上传到网关并以代理模式运行，
+由网关就近连接该子网的节点并把结果通过SSH通道返回，客户端只需维护少量连接。
+子网按IP前三段划分，与结果显示的分组一致。不带参数时列出组的网关。
+
+默认不会把目标节点的密码和私钥发给网关：本地设置了 SSH_AUTH_SOCK 时 SSH agent 会被转发到网关，
+网关使用转发的 agent 或网关上登录用户自己的私钥（~/.ssh/id_*）连接目标节点。
+只有指定 --send-credentials 时才会把节点的密码和私钥内容发给网关代理。`,
+	Example: `  talko group gateway web --subnet 10.0.1 --node 10.0.1.1
+  talko group gateway web --auto
+  talko group gateway web --subnet 10.0.2 --node 10.0.2.1 --send-credentials
+  talko group gateway web --remove --subnet 10.0.1`,
+	Run: groupGatewayFunc,
+}
+
+func init() {
+	groupGatewayCmd.Flags().StringVarP(&groupGatewayName, "name", "n", "", "组名称")
+	groupGatewayCmd.Flags().StringVarP(&groupGatewaySubnet, "subnet", "s", "", "子网，如 192.168.1")
+	groupGatewayCmd.Flags().StringVar(&groupGatewayNode, "node", "", "网关节点ID或IP")
+	groupGatewayCmd.Flags().BoolVar(&groupGatewayAuto, "auto", false, "为每个子网自动选择第一个可用节点作为网关")
+	groupGatewayCmd.Flags().BoolVar(&groupGatewayRemove, "remove", false, "移除网关，未指定子网时移除全部")
+	groupGatewayCmd.Flags().BoolVar(&groupGatewayCreds, "send-credentials", false, "把目标节点的密码和私钥发给网关代理（默认不发送）")
+}
+
+func groupGatewayFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		groupGatewayName = args[0]
+	}
+	if groupGatewayName == "" {
+		color.Red("Group name cannot be empty")
+		return
+	}
+	if _, err := crud.GetGroup(groupGatewayName); err != nil {
+		color.Red("Get group info failed: %v", err)
+		return
+	}
+
+	switch {
+	case groupGatewayRemove:
+		count, err := crud.RemoveGateway(groupGatewayName, groupGatewaySubnet)
+		if err != nil {
+			color.Red("Remove gateway failed: %v", err)
+			return
+		}
+		color.Green("Removed %d gateways", count)
+	case groupGatewayAuto:
+		autoGateways()
+	case groupGatewayNode != "":
+		node, err := crud.ResolveNodeRef(groupGatewayNode)
+		if err != nil {
+			color.Red("%v", err)
+			return
+		}
+		subnet := groupGatewaySubnet
+		if subnet == "" {
+			subnet = session.SubnetKey(node.IP)
+		}
+		if err := crud.SetGateway(groupGatewayName, subnet, node.ID, groupGatewayCreds); err != nil {
+			color.Red("Set gateway failed: %v", err)
+			return
+		}
+		color.Green("Subnet %s of group '%s' now uses gateway %s", subnet, groupGatewayName, node.IP)
+	default:
+		listGateways()
+	}
+}
+
+// autoGateways 为每个子网选择第一个可用节点作为网关
+func autoGateways() {
+	nodes, err := crud.GetNodesInGroup(groupGatewayName)
+	if err != nil {
+		color.Red("Get nodes info failed: %v", err)
+		return
+	}
+
+	chosen := make(map[string]string)
+	for _, node := range nodes {
+		subnet := session.SubnetKey(node.IP)
+		if _, ok := chosen[subnet]; ok || !node.Usable {
+			continue
+		}
+		chosen[subnet] = node.ID
+		if err := crud.SetGateway(groupGatewayName, subnet, node.ID, groupGatewayCreds); err != nil {
+			color.Red("Set gateway for %s failed: %v", subnet, err)
+			return
+		}
+		color.Green("%s -> %s", subnet, node.IP)
+	}
+
+	if len(chosen) == 0 {
+		color.Yellow("No usable nodes found")
+	}
+}
+
+// listGateways 列出组的网关
+func listGateways() {
+	gateways, err := crud.ListGateways(groupGatewayName)
+	if err != nil {
+		color.Red("List gateways failed: %v", err)
+		return
+	}
+	if len(gateways) == 0 {
+		fmt.Println("No gateways configured")
+		return
+	}
+
+	sort.Slice(gateways, func(i, j int) bool { return gateways[i].Subnet < gateways[j].Subnet })
+	fmt.Println("Gateways:")
+	for _, gw := range gateways {
+		if gw.SendCredentials {
+			fmt.Printf("  %s -> %s (send credentials)\n", gw.Subnet, gw.NodeID)
+		} else {
+			fmt.Printf("  %s -> %s\n", gw.Subnet, gw.NodeID)
+		}
+	}
+}
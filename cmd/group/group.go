@@ -19,6 +19,10 @@ func init() {
 	GroupCmd.AddCommand(groupListCmd)
 	GroupCmd.AddCommand(groupExecCmd)
 	GroupCmd.AddCommand(groupShowCmd)
+	GroupCmd.AddCommand(groupJumpCmd)
+	GroupCmd.AddCommand(groupGatewayCmd)
+	GroupCmd.AddCommand(groupLimitCmd)
+	GroupCmd.AddCommand(groupTopCmd)
 
 	GroupCmd.AddCommand(node.NodeCmd)
 }
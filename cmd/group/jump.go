@@ -0,0 +1,103 @@
+package group
+
+import (
+	"fmt"
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	groupJumpName  string
+	groupJumpVia   string
+	groupJumpNodes string
+	groupJumpClear bool
+)
+
+var groupJumpCmd = &cobra.Command{
+	Use:   "jump [group-name]",
+	Short: "设置组或节点的跳板机",
+	Long: `设置组或组内部分节点的跳板机链，跳板机必须是已保存的节点，可使用节点ID或IP引用。
+多个跳板机按顺序逐级跳转，节点级配置优先于组级配置。`,
+	Example: `  talko group jump web --via bastion-10.0.0.1
+  talko group jump web --via 10.0.0.1,172.16.0.1 --nodes 192.168.1.1-20
+  talko group jump web --clear`,
+	Run: groupJumpFunc,
+}
+
+func init() {
+	groupJumpCmd.Flags().StringVarP(&groupJumpName, "name", "n", "", "组名称")
+	groupJumpCmd.Flags().StringVar(&groupJumpVia, "via", "", "跳板机列表，逗号分隔的节点ID或IP")
+	groupJumpCmd.Flags().StringVarP(&groupJumpNodes, "nodes", "N", "", "只设置指定节点，支持范围表示法")
+	groupJumpCmd.Flags().BoolVar(&groupJumpClear, "clear", false, "清除跳板机配置")
+}
+
+func groupJumpFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		groupJumpName = args[0]
+	}
+	if groupJumpName == "" {
+		color.Red("Group name cannot be empty")
+		return
+	}
+	if groupJumpVia == "" && !groupJumpClear {
+		color.Red("Please specify --via or --clear")
+		return
+	}
+
+	jumpHosts := ""
+	if !groupJumpClear {
+		resolved, err := resolveJumpHosts(groupJumpVia)
+		if err != nil {
+			color.Red("%v", err)
+			return
+		}
+		jumpHosts = resolved
+	}
+
+	// 未指定节点时设置组级配置
+	if groupJumpNodes == "" {
+		if err := crud.SetGroupJumpHosts(groupJumpName, jumpHosts); err != nil {
+			color.Red("Set jump hosts failed: %v", err)
+			return
+		}
+		if jumpHosts == "" {
+			color.Green("Jump hosts of group '%s' cleared", groupJumpName)
+		} else {
+			color.Green("Group '%s' now connects via %s", groupJumpName, jumpHosts)
+		}
+		return
+	}
+
+	ips, err := ip_util.ParseIPRange(groupJumpNodes)
+	if err != nil {
+		color.Red("Parse nodes list failed: %v", err)
+		return
+	}
+	count, err := crud.SetNodesJumpHosts(groupJumpName, ips, jumpHosts)
+	if err != nil {
+		color.Red("Set jump hosts failed: %v", err)
+		return
+	}
+	color.Green("Updated jump hosts of %d nodes", count)
+}
+
+// resolveJumpHosts 将节点ID或IP列表解析为节点ID列表
+func resolveJumpHosts(refs string) (string, error) {
+	var ids []string
+	for _, ref := range sshconn.ParseJumpHosts(refs) {
+		node, err := crud.ResolveNodeRef(ref)
+		if err != nil {
+			return "", fmt.Errorf("resolve jump host failed: %v", err)
+		}
+		ids = append(ids, node.ID)
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("jump hosts cannot be empty")
+	}
+	return strings.Join(ids, ","), nil
+}
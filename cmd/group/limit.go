@@ -0,0 +1,65 @@
+package group
+
+import (
+	"fmt"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	groupLimitName     string
+	groupLimitFanout   int
+	groupLimitDialRate float64
+)
+
+var groupLimitCmd = &cobra.Command{
+	Use:   "limit [group-name]",
+	Short: "设置组的并发和建连速率限制",
+	Long: fmt.Sprintf(`设置对该组执行命令、验证连接等操作时同时处理的节点数上限和每秒新建连接数上限。
+设为 0 表示使用默认值（并发 %d，每秒 %d 个连接），命令行的 --fanout/--rate 优先于组配置。
+不带参数时显示当前配置。`, fanout.DefaultWorkers, fanout.DefaultDialRate),
+	Example: `  talko group limit web --fanout 100 --rate 20`,
+	Run:     groupLimitFunc,
+}
+
+func init() {
+	groupLimitCmd.Flags().StringVarP(&groupLimitName, "name", "n", "", "组名称")
+	groupLimitCmd.Flags().IntVar(&groupLimitFanout, "fanout", 0, "并发处理的节点数上限")
+	groupLimitCmd.Flags().Float64Var(&groupLimitDialRate, "rate", 0, "每秒新建连接数上限")
+}
+
+func groupLimitFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		groupLimitName = args[0]
+	}
+	if groupLimitName == "" {
+		color.Red("Group name cannot be empty")
+		return
+	}
+
+	group, err := crud.GetGroup(groupLimitName)
+	if err != nil {
+		color.Red("Get group info failed: %v", err)
+		return
+	}
+
+	// 未指定的项保持原值
+	if cmd.Flags().Changed("fanout") {
+		group.Fanout = groupLimitFanout
+	}
+	if cmd.Flags().Changed("rate") {
+		group.DialRate = groupLimitDialRate
+	}
+	if cmd.Flags().Changed("fanout") || cmd.Flags().Changed("rate") {
+		if err := crud.SetGroupLimits(group.Name, group.Fanout, group.DialRate); err != nil {
+			color.Red("Set group limits failed: %v", err)
+			return
+		}
+	}
+
+	limiter := fanout.ForGroup(group, 0, 0)
+	color.Green("Group '%s': fanout %d, %.1f dials/s", group.Name, limiter.Workers(), limiter.DialRate())
+}
@@ -5,14 +5,23 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/facts"
+	"zhaowanpeng/cluster-manager/internal/logic/health"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
 var (
-	groupShowName string
+	groupShowName   string
+	groupShowWindow string
+	groupShowFacts  bool
+	groupShowWhere  string
 )
 
 var groupShowCmd = &cobra.Command{
@@ -23,6 +32,9 @@ var groupShowCmd = &cobra.Command{
 
 func init() {
 	groupShowCmd.Flags().StringVarP(&groupShowName, "name", "n", "", "组名称")
+	groupShowCmd.Flags().BoolVar(&groupShowFacts, "facts", false, "显示节点的系统信息（见 facts）")
+	groupShowCmd.Flags().StringVarP(&groupShowWhere, "where", "W", "", "只显示系统信息满足条件的节点，如 os_id=ubuntu,cpus>=8")
+	groupShowCmd.Flags().StringVarP(&groupShowWindow, "window", "w", "24h", "统计可用率的时间窗口，如 24h、7d")
 }
 
 func groupShowFunc(cmd *cobra.Command, args []string) {
@@ -54,6 +66,17 @@ func groupShowFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	window, err := utils.ParseDuration(groupShowWindow)
+	if err != nil {
+		color.Red("Parse --window failed: %v", err)
+		return
+	}
+	stats, availability, err := health.GroupStats(group.Name, time.Now().Add(-window))
+	if err != nil {
+		color.Red("Get check history failed: %v", err)
+		return
+	}
+
 	// 获取组中的节点
 	nodes, err := crud.GetNodesInGroup(groupShowName)
 	if err != nil {
@@ -61,12 +84,40 @@ func groupShowFunc(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	if groupShowWhere != "" {
+		selector, err := facts.ParseSelector(groupShowWhere)
+		if err != nil {
+			color.Red("%v", err)
+			return
+		}
+		matched, unknown := selector.Filter(nodes)
+		if len(unknown) > 0 {
+			ips := make([]string, len(unknown))
+			for i, node := range unknown {
+				ips[i] = node.IP
+			}
+			color.Yellow("跳过没有采集过系统信息的节点: %s（使用 facts 命令采集）", session.CompressIPList(ips))
+		}
+		nodes = matched
+	}
+
 	// 显示组信息
 	color.Green("Group: %s", group.Name)
 	fmt.Printf("Description: %s\n", group.Description)
 	fmt.Printf("Created at: %s\n", group.CreatedAt.Format("2006-01-02 15:04:05"))
 	fmt.Printf("Updated at: %s\n", group.UpdatedAt.Format("2006-01-02 15:04:05"))
 	fmt.Printf("Node count: %d\n", len(nodes))
+	if group.JumpHosts != "" {
+		fmt.Printf("Jump hosts: %s\n", group.JumpHosts)
+	}
+	if availability < 0 {
+		fmt.Printf("Availability (%s): no checks, run 'check %s'\n", groupShowWindow, group.Name)
+	} else {
+		fmt.Printf("Availability (%s): %.1f%%\n", groupShowWindow, availability)
+	}
+	if flapping := health.FlappingNodes(stats); len(flapping) > 0 {
+		color.Yellow("Flapping nodes: %s", strings.Join(flapping, ", "))
+	}
 
 	if len(nodes) > 0 {
 		fmt.Println("\nNodes list:")
@@ -81,6 +132,25 @@ func groupShowFunc(cmd *cobra.Command, args []string) {
 
 			statusColor.Printf("%s %s", statusSymbol, node.IP)
 			fmt.Printf(" (Port: %d, User: %s)\n", node.Port, node.User)
+			if node.JumpHosts != "" {
+				fmt.Printf("  via %s\n", node.JumpHosts)
+			}
+			if !node.LastCheckAt.IsZero() {
+				fmt.Printf("  Last check: %s\n", node.LastCheckAt.Format("2006-01-02 15:04:05"))
+			}
+			if s, ok := stats[node.ID]; ok {
+				fmt.Printf("  Availability: %.1f%% of %d checks", s.Availability(), s.Checks)
+				if s.Flapping() {
+					color.New(color.FgYellow).Printf(", flapping (%d changes)", s.Changes)
+				}
+				fmt.Println()
+				if !s.Last.Usable {
+					color.Red("  Last error: %s", s.Last.Error)
+				}
+			}
+			if groupShowFacts {
+				showNodeFacts(node)
+			}
 
 			if i < len(nodes)-1 {
 				fmt.Println("----------------------------------------")
@@ -88,3 +158,25 @@ func groupShowFunc(cmd *cobra.Command, args []string) {
 		}
 	}
 }
+
+// showNodeFacts 显示节点保存的系统信息
+func showNodeFacts(node model.Node) {
+	f, ok := facts.Load(node)
+	if !ok {
+		fmt.Println("  Facts: not collected")
+		return
+	}
+
+	fmt.Printf("  Hostname: %s\n", f.Hostname)
+	fmt.Printf("  OS: %s (%s %s)\n", f.OS, f.OSID, f.OSVersion)
+	fmt.Printf("  Kernel: %s %s\n", f.Kernel, f.Arch)
+	fmt.Printf("  CPU: %d, Memory: %s, Virtualization: %s\n", f.CPUs, facts.FormatMB(f.MemoryMB), f.Virtualization)
+	fmt.Printf("  Uptime: %s\n", facts.FormatUptime(f.Uptime))
+	for _, disk := range f.Disks {
+		fmt.Printf("  Disk: %s %s/%s\n", disk.Mount, facts.FormatMB(disk.UsedMB), facts.FormatMB(disk.SizeMB))
+	}
+	if len(f.IPs) > 0 {
+		fmt.Printf("  IPs: %s\n", strings.Join(f.IPs, ", "))
+	}
+	fmt.Printf("  Facts collected at: %s\n", node.FactsAt.Format("2006-01-02 15:04:05"))
+}
@@ -0,0 +1,65 @@
+package group
+
+import (
+	"time"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	topGroupName string
+	topInterval  time.Duration
+	topTimeout   int
+	topExclude   string
+	topWhere     string
+	topFanout    int
+	topDialRate  float64
+)
+
+var groupTopCmd = &cobra.Command{
+	Use:   "top [group-name]",
+	Short: "全屏显示组内节点的实时状态",
+	Long: `类似 top 的全屏面板，定期通过长会话采集组内每个节点的负载、CPU、内存、根分区使用率和可达性。
+节点按 /24 子网分组显示，数字键 1-7 按对应列排序，j/k 或方向键选择节点，回车进入该节点的shell，q 退出。`,
+	Example: `  talko group top web
+  talko group top web -i 5s -e 192.168.1.10`,
+	Run: groupTopFunc,
+}
+
+func init() {
+	groupTopCmd.Flags().StringVarP(&topGroupName, "name", "n", "", "组名称")
+	groupTopCmd.Flags().DurationVarP(&topInterval, "interval", "i", 3*time.Second, "刷新间隔")
+	groupTopCmd.Flags().IntVarP(&topTimeout, "timeout", "t", 10, "每次采集的超时时间（秒）")
+	groupTopCmd.Flags().StringVarP(&topExclude, "exclude", "e", "", "排除节点，支持范围表示法")
+	groupTopCmd.Flags().StringVarP(&topWhere, "where", "W", "", "按节点系统信息筛选，如 os_id=ubuntu,cpus>=8（见 facts）")
+	groupTopCmd.Flags().IntVar(&topFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	groupTopCmd.Flags().Float64Var(&topDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+}
+
+func groupTopFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		topGroupName = args[0]
+	}
+	if topGroupName == "" {
+		color.Red("请提供组名称")
+		return
+	}
+	if topInterval < time.Second {
+		color.Red("刷新间隔不能小于 1s")
+		return
+	}
+
+	options := session.ExecOptions{
+		GroupName:    topGroupName,
+		Timeout:      time.Duration(topTimeout) * time.Second,
+		ExcludeNodes: topExclude,
+		Where:        topWhere,
+		Fanout:       topFanout,
+		DialRate:     topDialRate,
+	}
+	if err := session.RunDashboard(options, topInterval); err != nil {
+		color.Red("%v", err)
+	}
+}
@@ -0,0 +1,17 @@
+package history
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var HistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "命令历史管理",
+	Long:  "查看、回放和清理记录的会话历史",
+}
+
+func init() {
+	HistoryCmd.AddCommand(historyListCmd)
+	HistoryCmd.AddCommand(historyShowCmd)
+	HistoryCmd.AddCommand(historyPruneCmd)
+}
@@ -0,0 +1,43 @@
+package history
+
+import (
+	"fmt"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var historyListLimit int
+
+var historyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出最近的会话",
+	Run:   historyListFunc,
+}
+
+func init() {
+	historyListCmd.Flags().IntVarP(&historyListLimit, "limit", "l", 20, "显示的会话数量")
+}
+
+func historyListFunc(cmd *cobra.Command, args []string) {
+	sessions, err := session.GetRecentSessions(historyListLimit)
+	if err != nil {
+		color.Red("List sessions failed: %v", err)
+		return
+	}
+
+	if len(sessions) == 0 {
+		fmt.Println("No sessions found")
+		return
+	}
+
+	fmt.Println("Sessions list:")
+	fmt.Println("----------------------------------------")
+	for _, s := range sessions {
+		color.Green("%s  group: %s", s.ID, s.GroupName)
+		fmt.Printf("   User: %s\n", s.User)
+		fmt.Printf("   Started at: %s\n", s.StartTime.Format("2006-01-02 15:04:05"))
+		fmt.Println("----------------------------------------")
+	}
+}
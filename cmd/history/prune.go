@@ -0,0 +1,74 @@
+package history
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/utils"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	historyPruneOlderThan string
+	historyPruneKeep      int
+	historyPruneNoVacuum  bool
+)
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "按保留策略清理历史",
+	Long:  "删除早于指定时长的会话及其命令和输出，最近的 N 个会话始终保留",
+	Example: `  talko history prune --older-than 30d
+  talko history prune --older-than 30d --keep-sessions 100`,
+	Run: historyPruneFunc,
+}
+
+func init() {
+	historyPruneCmd.Flags().StringVar(&historyPruneOlderThan, "older-than", "", "清理早于该时长的会话，如 30d、12h")
+	historyPruneCmd.Flags().IntVar(&historyPruneKeep, "keep-sessions", 0, "始终保留最近的会话数")
+	historyPruneCmd.Flags().BoolVar(&historyPruneNoVacuum, "no-vacuum", false, "清理后不回收磁盘空间")
+}
+
+func historyPruneFunc(cmd *cobra.Command, args []string) {
+	if historyPruneOlderThan == "" && !cmd.Flags().Changed("keep-sessions") {
+		color.Red("Please specify --older-than or --keep-sessions")
+		return
+	}
+	if historyPruneKeep < 0 {
+		color.Red("--keep-sessions cannot be negative")
+		return
+	}
+	// 只按数量保留时，保留 0 个会话等于删除全部历史，不允许
+	if historyPruneOlderThan == "" && historyPruneKeep < 1 {
+		color.Red("--keep-sessions must be at least 1 when --older-than is not given")
+		return
+	}
+
+	var before time.Time
+	if historyPruneOlderThan != "" {
+		age, err := utils.ParseDuration(historyPruneOlderThan)
+		if err != nil {
+			color.Red("Parse --older-than failed: %v", err)
+			return
+		}
+		before = time.Now().Add(-age)
+	}
+
+	stats, err := crud.PruneHistory(before, historyPruneKeep)
+	if err != nil {
+		color.Red("Prune history failed: %v", err)
+		return
+	}
+
+	color.Green("Pruned %d sessions, %d commands, %d outputs", stats.Sessions, stats.Commands, stats.Outputs)
+
+	if stats.Sessions > 0 && !historyPruneNoVacuum {
+		if err := crud.CompactDB(); err != nil {
+			color.Yellow("Vacuum failed: %v", err)
+			return
+		}
+		fmt.Println("Database compacted")
+	}
+}
@@ -0,0 +1,21 @@
+package history
+
+import (
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var historyShowCmd = &cobra.Command{
+	Use:   "show <session-id>",
+	Short: "回放会话",
+	Args:  cobra.ExactArgs(1),
+	Run:   historyShowFunc,
+}
+
+func historyShowFunc(cmd *cobra.Command, args []string) {
+	if err := session.ReplaySession(args[0]); err != nil {
+		color.Red("Replay session failed: %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package jobs
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobsTimeout  int
+	jobsFanout   int
+	jobsDialRate float64
+)
+
+var JobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "后台作业管理",
+	Long:  "查看、读取、终止和清理通过 group exec --detach 在节点上后台运行的作业",
+}
+
+func init() {
+	JobsCmd.PersistentFlags().IntVarP(&jobsTimeout, "timeout", "t", 30, "查询每个节点的超时时间（秒）")
+	JobsCmd.PersistentFlags().IntVar(&jobsFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	JobsCmd.PersistentFlags().Float64Var(&jobsDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+
+	JobsCmd.AddCommand(jobsListCmd)
+	JobsCmd.AddCommand(jobsStatusCmd)
+	JobsCmd.AddCommand(jobsOutputCmd)
+	JobsCmd.AddCommand(jobsKillCmd)
+	JobsCmd.AddCommand(jobsPruneCmd)
+}
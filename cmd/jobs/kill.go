@@ -0,0 +1,43 @@
+package jobs
+
+import (
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/logic/jobs"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var jobsKillSignal string
+
+var jobsKillCmd = &cobra.Command{
+	Use:   "kill <job-id>",
+	Short: "终止作业",
+	Long:  "向作业在各节点上的整个进程组发送信号",
+	Args:  cobra.ExactArgs(1),
+	Run:   jobsKillFunc,
+}
+
+func init() {
+	jobsKillCmd.Flags().StringVarP(&jobsKillSignal, "signal", "s", "TERM", "发送的信号名，如 TERM、KILL、INT")
+}
+
+func jobsKillFunc(cmd *cobra.Command, args []string) {
+	signal := strings.TrimPrefix(strings.ToUpper(jobsKillSignal), "SIG")
+	if signal == "" || strings.Trim(signal, "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789") != "" {
+		color.Red("无效的信号名: %s", jobsKillSignal)
+		return
+	}
+
+	job, nodes, jobNodes, err := jobs.Load(args[0])
+	if err != nil {
+		color.Red("获取作业失败: %v", err)
+		return
+	}
+
+	limiter := jobs.Limiter(job, jobsFanout, jobsDialRate)
+	results := jobs.Kill(job, nodes, jobNodes, signal, limiter, time.Duration(jobsTimeout)*time.Second)
+	session.DisplayResults(nodes, results, true)
+}
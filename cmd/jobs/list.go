@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"fmt"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/logic/jobs"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var jobsListLimit int
+
+var jobsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出最近的作业",
+	Long:  "列出最近的作业，状态为各节点最近一次查询的结果，使用 jobs status 刷新",
+	Run:   jobsListFunc,
+}
+
+func init() {
+	jobsListCmd.Flags().IntVarP(&jobsListLimit, "limit", "l", 20, "显示的作业数量")
+}
+
+func jobsListFunc(cmd *cobra.Command, args []string) {
+	list, err := crud.ListJobs(jobsListLimit)
+	if err != nil {
+		color.Red("获取作业列表失败: %v", err)
+		return
+	}
+
+	if len(list) == 0 {
+		fmt.Println("没有作业")
+		return
+	}
+
+	fmt.Println("作业列表:")
+	fmt.Println("----------------------------------------")
+	for _, job := range list {
+		color.Green("%s  组: %s", job.ID, job.GroupName)
+		fmt.Printf("   命令: %s\n", job.Command)
+		fmt.Printf("   启动时间: %s\n", job.StartTime.Format("2006-01-02 15:04:05"))
+		if jobNodes, err := crud.GetJobNodes(job.ID); err == nil {
+			fmt.Printf("   节点: %s\n", jobs.Summary(jobNodes))
+		}
+		fmt.Println("----------------------------------------")
+	}
+}
@@ -0,0 +1,40 @@
+package jobs
+
+import (
+	"time"
+	"zhaowanpeng/cluster-manager/internal/logic/jobs"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	jobsOutputMerge bool
+	jobsOutputTail  int
+)
+
+var jobsOutputCmd = &cobra.Command{
+	Use:   "output <job-id>",
+	Short: "读取作业在各节点上的输出",
+	Long:  "读取作业在各节点上的输出，作业仍在运行时显示目前为止的输出",
+	Args:  cobra.ExactArgs(1),
+	Run:   jobsOutputFunc,
+}
+
+func init() {
+	jobsOutputCmd.Flags().BoolVarP(&jobsOutputMerge, "merge", "m", false, "合并相同输出")
+	jobsOutputCmd.Flags().IntVar(&jobsOutputTail, "tail", 0, "只显示最后 N 行，0 表示全部")
+}
+
+func jobsOutputFunc(cmd *cobra.Command, args []string) {
+	job, nodes, jobNodes, err := jobs.Load(args[0])
+	if err != nil {
+		color.Red("获取作业失败: %v", err)
+		return
+	}
+
+	limiter := jobs.Limiter(job, jobsFanout, jobsDialRate)
+	results := jobs.Output(job, nodes, jobNodes, jobsOutputTail, limiter, time.Duration(jobsTimeout)*time.Second)
+	session.DisplayResults(nodes, results, jobsOutputMerge)
+}
@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"time"
+	"zhaowanpeng/cluster-manager/internal/logic/jobs"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var jobsPruneCmd = &cobra.Command{
+	Use:   "prune <job-id>",
+	Short: "清理作业",
+	Long: `删除作业在各节点上的目录（输出、进程号和退出码），之后无法再读取作业的输出。
+仍在运行的节点不会被清理；所有节点都清理完成后同时删除本地的作业记录。`,
+	Args: cobra.ExactArgs(1),
+	Run:  jobsPruneFunc,
+}
+
+func jobsPruneFunc(cmd *cobra.Command, args []string) {
+	job, nodes, jobNodes, err := jobs.Load(args[0])
+	if err != nil {
+		color.Red("获取作业失败: %v", err)
+		return
+	}
+
+	limiter := jobs.Limiter(job, jobsFanout, jobsDialRate)
+	results, pruned, err := jobs.Prune(job, nodes, jobNodes, limiter, time.Duration(jobsTimeout)*time.Second)
+	session.DisplayResults(nodes, results, true)
+	switch {
+	case err != nil:
+		color.Red("%v", err)
+	case pruned:
+		color.Green("作业 %s 已清理", job.ID)
+	default:
+		color.Yellow("部分节点未清理，作业记录已保留，可稍后重试")
+	}
+}
@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"time"
+	"zhaowanpeng/cluster-manager/internal/logic/jobs"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var jobsStatusCmd = &cobra.Command{
+	Use:   "status <job-id>",
+	Short: "查询作业在各节点上的状态",
+	Args:  cobra.ExactArgs(1),
+	Run:   jobsStatusFunc,
+}
+
+func jobsStatusFunc(cmd *cobra.Command, args []string) {
+	job, nodes, jobNodes, err := jobs.Load(args[0])
+	if err != nil {
+		color.Red("获取作业失败: %v", err)
+		return
+	}
+
+	color.Cyan("作业 %s  组: %s  命令: %s", job.ID, job.GroupName, job.Command)
+	limiter := jobs.Limiter(job, jobsFanout, jobsDialRate)
+	results := jobs.Status(job, nodes, jobNodes, limiter, time.Duration(jobsTimeout)*time.Second)
+	session.DisplayResults(nodes, results, true)
+}
@@ -0,0 +1,15 @@
+package keys
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var KeysCmd = &cobra.Command{
+	Use:   "keys",
+	Short: "SSH 公钥管理",
+	Long:  "分发 SSH 公钥，并将节点从密码认证迁移到密钥认证",
+}
+
+func init() {
+	KeysCmd.AddCommand(keysPushCmd)
+}
@@ -0,0 +1,171 @@
+package keys
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/logic/keys"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	keysPushGroupName string
+	keysPushKey       string
+	keysPushGenerate  bool
+	keysPushNoMigrate bool
+	keysPushTimeout   int
+	keysPushFanout    int
+	keysPushDialRate  float64
+)
+
+var keysPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "向组内所有节点分发公钥",
+	Long: `将公钥幂等地追加到组内所有节点的 ~/.ssh/authorized_keys。
+默认使用 ~/.cluster-manager/id_ed25519.pub，--generate 会先生成该密钥对。
+分发后逐个节点验证密钥登录，验证通过的节点切换为密钥认证并清除保存的密码。`,
+	Example: `  talko keys push -g web --generate
+  talko keys push -g web --key ~/.ssh/id_ed25519.pub`,
+	Run: keysPushFunc,
+}
+
+func init() {
+	keysPushCmd.Flags().StringVarP(&keysPushGroupName, "group", "g", "", "组名称")
+	keysPushCmd.Flags().StringVarP(&keysPushKey, "key", "k", "", "公钥文件，对应的私钥需位于去掉 .pub 后缀的路径")
+	keysPushCmd.Flags().BoolVar(&keysPushGenerate, "generate", false, "生成工具专用的 ed25519 密钥对")
+	keysPushCmd.Flags().BoolVar(&keysPushNoMigrate, "no-migrate", false, "只分发公钥，不切换节点认证方式")
+	keysPushCmd.Flags().IntVarP(&keysPushTimeout, "timeout", "t", 30, "命令执行超时时间（秒）")
+	keysPushCmd.Flags().IntVar(&keysPushFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	keysPushCmd.Flags().Float64Var(&keysPushDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+}
+
+func keysPushFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		keysPushGroupName = args[0]
+	}
+	if keysPushGroupName == "" {
+		color.Red("Please specify group with -g")
+		return
+	}
+
+	pubPath, err := resolvePublicKey()
+	if err != nil {
+		color.Red("%v", err)
+		return
+	}
+	authorizedKey, err := utils.LoadAuthorizedKey(pubPath)
+	if err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	group, err := crud.GetGroup(keysPushGroupName)
+	if err != nil {
+		color.Red("Get group info failed: %v", err)
+		return
+	}
+	limiter := fanout.ForGroup(group, keysPushFanout, keysPushDialRate)
+
+	nodes, err := crud.GetNodesInGroup(keysPushGroupName)
+	if err != nil {
+		color.Red("Get nodes info failed: %v", err)
+		return
+	}
+	if len(nodes) == 0 {
+		color.Red("Group '%s' has no nodes", keysPushGroupName)
+		return
+	}
+
+	// 分发公钥
+	fmt.Printf("Pushing %s to %d nodes...\n", pubPath, len(nodes))
+	timeout := time.Duration(keysPushTimeout) * time.Second
+	sessionManager := session.NewSessionManager()
+	sessionManager.SetLimiter(limiter)
+	results := sessionManager.RunCommand(nodes, keys.AuthorizeCommand(authorizedKey), timeout)
+	sessionManager.CloseAll()
+	session.DisplayResults(nodes, results, true)
+
+	if keysPushNoMigrate {
+		return
+	}
+
+	// 只迁移公钥分发成功的节点
+	keyPath := strings.TrimSuffix(pubPath, ".pub")
+	if keyPath == pubPath {
+		color.Yellow("Private key for %s not found, skip migration", pubPath)
+		return
+	}
+	var pushed []model.Node
+	for _, node := range nodes {
+		if result, ok := results[node.IP]; ok && result.Success {
+			pushed = append(pushed, node)
+		}
+	}
+	if len(pushed) == 0 {
+		return
+	}
+
+	fmt.Println("Verifying key login...")
+	migrateResults, err := keys.MigrateNodes(pushed, keyPath, timeout, limiter)
+	if err != nil {
+		color.Red("Migrate nodes failed: %v", err)
+		return
+	}
+
+	successCount := 0
+	failureMap := make(map[string][]string)
+	for _, result := range migrateResults {
+		if result.Success {
+			successCount++
+		} else {
+			failureMap[result.Msg] = append(failureMap[result.Msg], result.IP)
+		}
+	}
+
+	color.Green("✓ %d/%d nodes switched to key auth, stored passwords wiped", successCount, len(nodes))
+	for errMsg, failedIPs := range failureMap {
+		color.Red("! %s", errMsg)
+		fmt.Printf("  - %s\n", session.CompressIPList(failedIPs))
+	}
+}
+
+// resolvePublicKey 确定要分发的公钥文件，需要时生成工具专用密钥对
+func resolvePublicKey() (string, error) {
+	if keysPushKey != "" {
+		if keysPushGenerate {
+			return "", fmt.Errorf("--key and --generate cannot be used together")
+		}
+		return keysPushKey, nil
+	}
+
+	appDir, err := model.AppDir()
+	if err != nil {
+		return "", err
+	}
+	keyPath := filepath.Join(appDir, "id_ed25519")
+
+	if keysPushGenerate {
+		if _, err := os.Stat(keyPath); err == nil {
+			color.Yellow("Key %s already exists, reuse it", keyPath)
+		} else {
+			hostname, _ := os.Hostname()
+			if _, err := utils.GenerateKeyPair(keyPath, "cluster-manager@"+hostname); err != nil {
+				return "", err
+			}
+			color.Green("Generated key pair %s", keyPath)
+		}
+	} else if _, err := os.Stat(keyPath + ".pub"); err != nil {
+		return "", fmt.Errorf("default key %s.pub not found, use --generate or --key", keyPath)
+	}
+
+	return keyPath + ".pub", nil
+}
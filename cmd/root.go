@@ -4,7 +4,15 @@ import (
 	"fmt"
 	"os"
 
+	"zhaowanpeng/cluster-manager/cmd/db"
 	"zhaowanpeng/cluster-manager/cmd/group"
+	"zhaowanpeng/cluster-manager/cmd/history"
+	"zhaowanpeng/cluster-manager/cmd/jobs"
+	"zhaowanpeng/cluster-manager/cmd/keys"
+	"zhaowanpeng/cluster-manager/cmd/rotate"
+	"zhaowanpeng/cluster-manager/cmd/schedule"
+	"zhaowanpeng/cluster-manager/cmd/users"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
 
 	"github.com/spf13/cobra"
 )
@@ -29,7 +37,10 @@ OctoShell (octosh) - 智能化多节点管理工具
 
 // Execute 执行根命令
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	err := rootCmd.Execute()
+	// 关闭进程内共享的SSH连接
+	sshconn.Shared.CloseAll()
+	if err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -40,6 +51,17 @@ func init() {
 	// rootCmd.AddCommand(listCmd)
 	// rootCmd.AddCommand(deleteCmd)
 	rootCmd.AddCommand(group.GroupCmd)
+	rootCmd.AddCommand(db.DBCmd)
+	rootCmd.AddCommand(history.HistoryCmd)
+	rootCmd.AddCommand(jobs.JobsCmd)
+	rootCmd.AddCommand(users.UsersCmd)
+	rootCmd.AddCommand(keys.KeysCmd)
+	rootCmd.AddCommand(rotate.RotateCmd)
+	rootCmd.AddCommand(schedule.ScheduleCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(checkCmd)
+	rootCmd.AddCommand(factsCmd)
+	rootCmd.AddCommand(agentCmd)
 	// rootCmd.AddCommand(execCmd)
 	// rootCmd.AddCommand(scpCmd)
 
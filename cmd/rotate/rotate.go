@@ -0,0 +1,177 @@
+package rotate
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/logic/rotate"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/types"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rotateGroupName string
+	rotateNodes     string
+	rotatePerGroup  bool
+	rotateLength    int
+	rotateTimeout   int
+	rotateShow      bool
+	rotateForce     bool
+	rotateFanout    int
+	rotateDialRate  float64
+)
+
+var RotateCmd = &cobra.Command{
+	Use:   "rotate-passwords",
+	Short: "轮换节点登录密码",
+	Long: `为组内节点生成强随机密码，通过现有会话使用 chpasswd 修改，
+并用新密码重新登录验证，验证通过后才更新数据库；验证失败时自动恢复旧密码。`,
+	Example: `  talko rotate-passwords -g web
+  talko rotate-passwords -g web --per-group --show`,
+	Run: rotateFunc,
+}
+
+func init() {
+	RotateCmd.Flags().StringVarP(&rotateGroupName, "group", "g", "", "组名称")
+	RotateCmd.Flags().StringVarP(&rotateNodes, "nodes", "N", "", "只轮换指定节点，支持范围表示法")
+	RotateCmd.Flags().BoolVar(&rotatePerGroup, "per-group", false, "组内所有节点使用同一个密码")
+	RotateCmd.Flags().IntVar(&rotateLength, "length", 24, "密码长度")
+	RotateCmd.Flags().IntVarP(&rotateTimeout, "timeout", "t", 30, "单个节点的超时时间（秒）")
+	RotateCmd.Flags().BoolVar(&rotateShow, "show", false, "显示生成的新密码")
+	RotateCmd.Flags().BoolVarP(&rotateForce, "force", "f", false, "不确认直接执行")
+	RotateCmd.Flags().IntVar(&rotateFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	RotateCmd.Flags().Float64Var(&rotateDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+}
+
+func rotateFunc(cmd *cobra.Command, args []string) {
+	if len(args) > 0 {
+		rotateGroupName = args[0]
+	}
+	if rotateGroupName == "" {
+		color.Red("Please specify group with -g")
+		return
+	}
+
+	group, err := crud.GetGroup(rotateGroupName)
+	if err != nil {
+		color.Red("Get group info failed: %v", err)
+		return
+	}
+
+	nodes, err := crud.GetNodesInGroup(rotateGroupName)
+	if err != nil {
+		color.Red("Get nodes info failed: %v", err)
+		return
+	}
+	if rotateNodes != "" {
+		ips, err := ip_util.ParseIPRange(rotateNodes)
+		if err != nil {
+			color.Red("Parse nodes list failed: %v", err)
+			return
+		}
+		nodes = filterNodes(nodes, ips)
+	}
+	if len(nodes) == 0 {
+		color.Red("No nodes to rotate")
+		return
+	}
+
+	// 确认执行
+	if !rotateForce {
+		fmt.Printf("Rotate passwords of %d nodes in group '%s'? [y/N]: ", len(nodes), rotateGroupName)
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			color.Red("Read input failed: %v", err)
+			return
+		}
+		input = strings.ToLower(strings.TrimSpace(input))
+		if input != "y" && input != "yes" {
+			fmt.Println("Operation cancelled")
+			return
+		}
+	}
+
+	plan, err := rotate.NewPlan(nodes, rotateLength, rotatePerGroup)
+	if err != nil {
+		color.Red("Generate passwords failed: %v", err)
+		return
+	}
+
+	sessionManager := session.NewSessionManager()
+	sessionManager.SetLimiter(fanout.ForGroup(group, rotateFanout, rotateDialRate))
+	defer sessionManager.CloseAll()
+
+	results := rotate.Apply(sessionManager, nodes, plan, time.Duration(rotateTimeout)*time.Second)
+
+	// 统计结果
+	successCount := 0
+	failureMap := make(map[string][]string)
+	for _, result := range results {
+		if result.Success {
+			successCount++
+		} else {
+			failureMap[result.Msg] = append(failureMap[result.Msg], result.IP)
+		}
+	}
+
+	color.Green("✓ Rotated passwords on %d/%d nodes", successCount, len(nodes))
+	for msg, ips := range failureMap {
+		color.Red("! %s", msg)
+		fmt.Printf("  - %s\n", session.CompressIPList(ips))
+	}
+
+	if rotateShow {
+		showPasswords(nodes, plan, results)
+	}
+}
+
+// showPasswords 显示轮换成功的节点的新密码，相同密码的节点合并显示
+func showPasswords(nodes []model.Node, plan rotate.Plan, results []types.Result) {
+	rotated := make(map[string]bool)
+	for _, result := range results {
+		if result.Success {
+			rotated[result.IP] = true
+		}
+	}
+
+	passwordIPs := make(map[string][]string)
+	var passwords []string
+	for _, node := range nodes {
+		if !rotated[node.IP] {
+			continue
+		}
+		password := plan[node.ID]
+		if _, ok := passwordIPs[password]; !ok {
+			passwords = append(passwords, password)
+		}
+		passwordIPs[password] = append(passwordIPs[password], node.IP)
+	}
+
+	for _, password := range passwords {
+		fmt.Printf("[%s] %s\n", session.CompressIPList(passwordIPs[password]), password)
+	}
+}
+
+// filterNodes 只保留指定IP的节点
+func filterNodes(nodes []model.Node, ips []string) []model.Node {
+	wanted := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		wanted[ip] = true
+	}
+	var filtered []model.Node
+	for _, node := range nodes {
+		if wanted[node.IP] {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,109 @@
+package schedule
+
+import (
+	"os"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/logic/schedule"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	scheduleAddGroup     string
+	scheduleAddCron      string
+	scheduleAddEvery     string
+	scheduleAddCommands  []string
+	scheduleAddFile      string
+	scheduleAddTimeout   int
+	scheduleAddExclude   string
+	scheduleAddNormalize bool
+	scheduleAddNotify    string
+	scheduleAddWebhook   string
+)
+
+var scheduleAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "添加定时任务",
+	Long: `添加定时任务。命令用 -c 指定（可多次指定），或用 -f 从文件读取（每行一条，# 开头为注释），
+多条命令在同一会话中依次执行，某条命令失败的节点不再执行后续命令。
+结果变化时，通知命令通过环境变量 CM_SCHEDULE、CM_GROUP、CM_SESSION 获取任务信息，
+从标准输入读取两次合并结果的差异；webhook 收到包含两次结果和差异的 JSON。`,
+	Example: `  talko schedule add disk -n web --cron "*/10 * * * *" -c "df -h /" --normalize --notify "mail -s disk ops@example.com"
+  talko schedule add nginx -n web --every 1h -f check-nginx.txt --webhook https://hooks.example.com/cm`,
+	Args: cobra.ExactArgs(1),
+	Run:  scheduleAddFunc,
+}
+
+func init() {
+	scheduleAddCmd.Flags().StringVarP(&scheduleAddGroup, "name", "n", "", "组名称")
+	scheduleAddCmd.Flags().StringVar(&scheduleAddCron, "cron", "", "cron 表达式（分 时 日 月 周），或 @hourly、@daily 等简写")
+	scheduleAddCmd.Flags().StringVar(&scheduleAddEvery, "every", "", "执行间隔，如 30s、10m、1d")
+	scheduleAddCmd.Flags().StringArrayVarP(&scheduleAddCommands, "command", "c", nil, "要执行的命令，可多次指定")
+	scheduleAddCmd.Flags().StringVarP(&scheduleAddFile, "file", "f", "", "从文件读取要执行的命令，每行一条")
+	scheduleAddCmd.Flags().IntVarP(&scheduleAddTimeout, "timeout", "t", 60, "每条命令的超时时间（秒）")
+	scheduleAddCmd.Flags().StringVarP(&scheduleAddExclude, "exclude", "e", "", "排除节点，支持范围表示法")
+	scheduleAddCmd.Flags().BoolVar(&scheduleAddNormalize, "normalize", false, "比较结果前屏蔽IP、主机名、数字等因节点而异或每次都变的部分")
+	scheduleAddCmd.Flags().StringVar(&scheduleAddNotify, "notify", "", "结果变化时在本机执行的命令")
+	scheduleAddCmd.Flags().StringVar(&scheduleAddWebhook, "webhook", "", "结果变化时 POST 通知的地址")
+}
+
+func scheduleAddFunc(cmd *cobra.Command, args []string) {
+	if scheduleAddGroup == "" {
+		color.Red("Group name cannot be empty")
+		return
+	}
+	if _, err := crud.GetGroup(scheduleAddGroup); err != nil {
+		color.Red("Get group info failed: %v", err)
+		return
+	}
+
+	commands := scheduleAddCommands
+	if scheduleAddFile != "" {
+		data, err := os.ReadFile(scheduleAddFile)
+		if err != nil {
+			color.Red("Read command file failed: %v", err)
+			return
+		}
+		commands = append(commands, string(data))
+	}
+
+	s := model.Schedule{
+		Name:          args[0],
+		GroupName:     scheduleAddGroup,
+		Commands:      strings.Join(commands, "\n"),
+		Cron:          scheduleAddCron,
+		Timeout:       scheduleAddTimeout,
+		Exclude:       scheduleAddExclude,
+		Normalize:     scheduleAddNormalize,
+		NotifyCommand: scheduleAddNotify,
+		NotifyWebhook: scheduleAddWebhook,
+		CreatedAt:     time.Now(),
+	}
+	if scheduleAddEvery != "" {
+		every, err := utils.ParseDuration(scheduleAddEvery)
+		if err != nil {
+			color.Red("Parse --every failed: %v", err)
+			return
+		}
+		s.Interval = int64(every / time.Second)
+	}
+
+	if err := schedule.Validate(s); err != nil {
+		color.Red("%v", err)
+		return
+	}
+	if err := crud.CreateSchedule(s); err != nil {
+		color.Red("Create schedule failed: %v", err)
+		return
+	}
+
+	color.Green("Schedule '%s' created: %s on group '%s', %d commands", s.Name, schedule.Describe(s), s.GroupName, len(schedule.Commands(s)))
+	if next, err := schedule.NextRun(s, time.Now()); err == nil {
+		color.Cyan("Next run at %s (requires a running daemon)", next.Format("2006-01-02 15:04:05"))
+	}
+}
@@ -0,0 +1,24 @@
+package schedule
+
+import (
+	"zhaowanpeng/cluster-manager/internal/crud"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var scheduleDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "删除定时任务",
+	Long:  "删除定时任务，运行中的 daemon 在下一次检查时停止执行该任务，已记录的会话保留",
+	Args:  cobra.ExactArgs(1),
+	Run:   scheduleDeleteFunc,
+}
+
+func scheduleDeleteFunc(cmd *cobra.Command, args []string) {
+	if err := crud.DeleteSchedule(args[0]); err != nil {
+		color.Red("Delete schedule failed: %v", err)
+		return
+	}
+	color.Green("Schedule '%s' deleted", args[0])
+}
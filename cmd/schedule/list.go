@@ -0,0 +1,51 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/logic/schedule"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var scheduleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "列出定时任务",
+	Run:   scheduleListFunc,
+}
+
+func scheduleListFunc(cmd *cobra.Command, args []string) {
+	schedules, err := crud.ListSchedules()
+	if err != nil {
+		color.Red("List schedules failed: %v", err)
+		return
+	}
+
+	if len(schedules) == 0 {
+		fmt.Println("No schedules found")
+		return
+	}
+
+	fmt.Println("Schedules list:")
+	fmt.Println("----------------------------------------")
+	for _, s := range schedules {
+		color.Green("%s  group: %s  %s", s.Name, s.GroupName, schedule.Describe(s))
+		for _, command := range schedule.Commands(s) {
+			fmt.Printf("   $ %s\n", command)
+		}
+		if s.LastRunAt.IsZero() {
+			fmt.Println("   Last run: never")
+		} else {
+			fmt.Printf("   Last run: %s (session %s)\n", s.LastRunAt.Format("2006-01-02 15:04:05"), s.LastSessionID)
+		}
+		if !s.LastChangedAt.IsZero() {
+			fmt.Printf("   Last changed: %s\n", s.LastChangedAt.Format("2006-01-02 15:04:05"))
+		}
+		if next, err := schedule.NextRun(s, time.Now()); err == nil {
+			fmt.Printf("   Next run: %s\n", next.Format("2006-01-02 15:04:05"))
+		}
+		fmt.Println("----------------------------------------")
+	}
+}
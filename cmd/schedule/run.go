@@ -0,0 +1,59 @@
+package schedule
+
+import (
+	"fmt"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/logic/schedule"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var scheduleRunMerge bool
+
+var scheduleRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "立即执行一次定时任务",
+	Long:  "立即执行一次定时任务并显示结果，与 daemon 执行时一样记录会话、比较结果和发送通知",
+	Args:  cobra.ExactArgs(1),
+	Run:   scheduleRunFunc,
+}
+
+func init() {
+	scheduleRunCmd.Flags().BoolVarP(&scheduleRunMerge, "merge", "m", true, "合并相同输出")
+}
+
+func scheduleRunFunc(cmd *cobra.Command, args []string) {
+	s, err := crud.GetSchedule(args[0])
+	if err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	result, err := schedule.Run(s)
+	if err != nil {
+		color.Red("Run schedule failed: %v", err)
+		if result == nil {
+			return
+		}
+	}
+
+	for _, step := range result.Steps {
+		color.Cyan("$ %s", step.Command)
+		session.DisplayResults(step.Nodes, step.Results, scheduleRunMerge)
+	}
+
+	fmt.Printf("Session: %s\n", result.SessionID)
+	switch {
+	case result.Previous == "":
+		color.Cyan("First run, result saved as baseline")
+	case result.Changed:
+		color.Yellow("Result changed since last run")
+	default:
+		color.Green("Result unchanged since last run")
+	}
+	if result.NotifyErr != nil {
+		color.Red("Notify failed: %v", result.NotifyErr)
+	}
+}
@@ -0,0 +1,19 @@
+package schedule
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var ScheduleCmd = &cobra.Command{
+	Use:   "schedule",
+	Short: "定时任务管理",
+	Long: `在组上按 cron 表达式或固定间隔定期执行命令，由 daemon 命令运行。
+每次执行记录为一个会话（见 history），合并结果与上次不同时执行通知命令或调用 webhook。`,
+}
+
+func init() {
+	ScheduleCmd.AddCommand(scheduleAddCmd)
+	ScheduleCmd.AddCommand(scheduleListCmd)
+	ScheduleCmd.AddCommand(scheduleDeleteCmd)
+	ScheduleCmd.AddCommand(scheduleRunCmd)
+}
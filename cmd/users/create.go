@@ -0,0 +1,77 @@
+package users
+
+import (
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/logic/users"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usersCreateUID    int
+	usersCreateGID    int
+	usersCreateShell  string
+	usersCreateGroups string
+)
+
+var usersCreateCmd = &cobra.Command{
+	Use:   "create <user>",
+	Short: "在组内所有节点上创建账户",
+	Args:  cobra.ExactArgs(1),
+	Run:   usersCreateFunc,
+}
+
+func init() {
+	usersCreateCmd.Flags().IntVarP(&usersCreateUID, "uid", "u", 0, "UID，默认由系统分配")
+	usersCreateCmd.Flags().IntVar(&usersCreateGID, "gid", 0, "主组 GID，默认由系统分配")
+	usersCreateCmd.Flags().StringVarP(&usersCreateShell, "shell", "s", "/bin/bash", "登录 shell")
+	usersCreateCmd.Flags().StringVarP(&usersCreateGroups, "groups", "G", "", "附加组，逗号分隔")
+}
+
+func usersCreateFunc(cmd *cobra.Command, args []string) {
+	account := users.Account{
+		Name:   args[0],
+		UID:    usersCreateUID,
+		GID:    usersCreateGID,
+		Shell:  usersCreateShell,
+		Groups: splitList(usersCreateGroups),
+	}
+
+	if err := validateNames(account.Name, account.Groups...); err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	nodes, results, err := runOnGroup(users.CreateCommand(account))
+	if err != nil {
+		color.Red("Create user failed: %v", err)
+		return
+	}
+	session.DisplayResults(nodes, results, true)
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateNames 校验用户名和组名
+func validateNames(name string, groups ...string) error {
+	if err := users.ValidateName(name); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if err := users.ValidateName(g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package users
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/logic/users"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usersDeleteRemoveHome bool
+	usersDeleteForce      bool
+)
+
+var usersDeleteCmd = &cobra.Command{
+	Use:   "delete <user>",
+	Short: "在组内所有节点上删除账户",
+	Args:  cobra.ExactArgs(1),
+	Run:   usersDeleteFunc,
+}
+
+func init() {
+	usersDeleteCmd.Flags().BoolVarP(&usersDeleteRemoveHome, "remove-home", "r", false, "同时删除家目录")
+	usersDeleteCmd.Flags().BoolVarP(&usersDeleteForce, "force", "f", false, "强制删除")
+}
+
+func usersDeleteFunc(cmd *cobra.Command, args []string) {
+	name := args[0]
+	if err := users.ValidateName(name); err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	// 确认删除
+	if !usersDeleteForce {
+		fmt.Printf("Are you sure you want to delete user '%s' on group '%s'? [y/N]: ", name, usersGroupName)
+		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			color.Red("Read input failed: %v", err)
+			return
+		}
+		input = strings.ToLower(strings.TrimSpace(input))
+		if input != "y" && input != "yes" {
+			fmt.Println("Operation cancelled")
+			return
+		}
+	}
+
+	nodes, results, err := runOnGroup(users.DeleteCommand(name, usersDeleteRemoveHome))
+	if err != nil {
+		color.Red("Delete user failed: %v", err)
+		return
+	}
+	session.DisplayResults(nodes, results, true)
+}
@@ -0,0 +1,119 @@
+package users
+
+import (
+	"fmt"
+	"sort"
+	"zhaowanpeng/cluster-manager/internal/logic/users"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usersDriftUID int
+	usersDriftGID int
+)
+
+var usersDriftCmd = &cobra.Command{
+	Use:   "drift <user>",
+	Short: "检查账户漂移",
+	Long:  "检查组内哪些节点缺少该账户，或 UID/GID 与期望值不一致；未指定期望值时以多数节点为准",
+	Args:  cobra.ExactArgs(1),
+	Run:   usersDriftFunc,
+}
+
+func init() {
+	usersDriftCmd.Flags().IntVarP(&usersDriftUID, "uid", "u", -1, "期望的 UID，默认取多数节点的值")
+	usersDriftCmd.Flags().IntVar(&usersDriftGID, "gid", -1, "期望的 GID，默认取多数节点的值")
+}
+
+func usersDriftFunc(cmd *cobra.Command, args []string) {
+	name := args[0]
+	if err := users.ValidateName(name); err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	nodes, results, err := runOnGroup(users.InspectCommand(name))
+	if err != nil {
+		color.Red("Inspect user failed: %v", err)
+		return
+	}
+
+	identities := make(map[string]users.Identity)
+	var missing, unknown []string
+	for _, node := range nodes {
+		result, ok := results[node.IP]
+		if !ok || !result.Success {
+			unknown = append(unknown, node.IP)
+			continue
+		}
+		id, exists, err := users.ParseIdentity(result.Output)
+		if err != nil {
+			unknown = append(unknown, node.IP)
+			continue
+		}
+		if !exists {
+			missing = append(missing, node.IP)
+			continue
+		}
+		identities[node.IP] = id
+	}
+
+	// 确定期望的身份
+	expected, ok := users.MajorityIdentity(identities)
+	if usersDriftUID >= 0 {
+		expected.UID, ok = usersDriftUID, true
+	}
+	if usersDriftGID >= 0 {
+		expected.GID = usersDriftGID
+	}
+
+	mismatched := make(map[users.Identity][]string)
+	var consistent []string
+	for _, node := range nodes {
+		id, exists := identities[node.IP]
+		if !exists {
+			continue
+		}
+		if id == expected {
+			consistent = append(consistent, node.IP)
+		} else {
+			mismatched[id] = append(mismatched[id], node.IP)
+		}
+	}
+
+	if ok {
+		color.Cyan("User '%s' expected %s", name, expected)
+	}
+	if len(consistent) > 0 {
+		color.Green("[%s] consistent (%d nodes)", session.CompressIPList(consistent), len(consistent))
+	}
+	ids := make([]users.Identity, 0, len(mismatched))
+	for id := range mismatched {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].UID != ids[j].UID {
+			return ids[i].UID < ids[j].UID
+		}
+		return ids[i].GID < ids[j].GID
+	})
+	for _, id := range ids {
+		ips := mismatched[id]
+		color.Yellow("[%s] drift: %s (%d nodes)", session.CompressIPList(ips), id, len(ips))
+	}
+	if len(missing) > 0 {
+		color.Red("[%s] missing (%d nodes)", session.CompressIPList(missing), len(missing))
+	}
+	if len(unknown) > 0 {
+		color.Red("[%s] unreachable or unknown (%d nodes)", session.CompressIPList(unknown), len(unknown))
+	}
+
+	drifted := len(missing) + len(unknown)
+	for _, ips := range mismatched {
+		drifted += len(ips)
+	}
+	fmt.Printf("%d/%d nodes drifted\n", drifted, len(nodes))
+}
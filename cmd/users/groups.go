@@ -0,0 +1,37 @@
+package users
+
+import (
+	"zhaowanpeng/cluster-manager/internal/logic/users"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var usersGroupsCmd = &cobra.Command{
+	Use:   "groups <user> <group>[,<group>...]",
+	Short: "将账户加入附加组",
+	Long:  "在组内所有节点上将账户加入附加组，不存在的组会自动创建",
+	Args:  cobra.ExactArgs(2),
+	Run:   usersGroupsFunc,
+}
+
+func usersGroupsFunc(cmd *cobra.Command, args []string) {
+	name := args[0]
+	groups := splitList(args[1])
+	if len(groups) == 0 {
+		color.Red("Groups cannot be empty")
+		return
+	}
+	if err := validateNames(name, groups...); err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	nodes, results, err := runOnGroup(users.AddGroupsCommand(name, groups))
+	if err != nil {
+		color.Red("Add groups failed: %v", err)
+		return
+	}
+	session.DisplayResults(nodes, results, true)
+}
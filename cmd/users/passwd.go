@@ -0,0 +1,85 @@
+package users
+
+import (
+	"fmt"
+	"syscall"
+	"zhaowanpeng/cluster-manager/internal/logic/users"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/utils"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	usersPasswdGenerate bool
+	usersPasswdLength   int
+)
+
+var usersPasswdCmd = &cobra.Command{
+	Use:   "passwd <user>",
+	Short: "设置或轮换账户密码",
+	Long:  "在组内所有节点上设置账户密码，使用 --generate 生成随机密码进行轮换",
+	Args:  cobra.ExactArgs(1),
+	Run:   usersPasswdFunc,
+}
+
+func init() {
+	usersPasswdCmd.Flags().BoolVar(&usersPasswdGenerate, "generate", false, "生成随机密码")
+	usersPasswdCmd.Flags().IntVar(&usersPasswdLength, "length", 20, "随机密码长度")
+}
+
+func usersPasswdFunc(cmd *cobra.Command, args []string) {
+	name := args[0]
+	if err := users.ValidateName(name); err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	var password string
+	if usersPasswdGenerate {
+		generated, err := utils.GeneratePassword(usersPasswdLength)
+		if err != nil {
+			color.Red("Generate password failed: %v", err)
+			return
+		}
+		password = generated
+	} else {
+		fmt.Print("New Password: ")
+		first, err := term.ReadPassword(int(syscall.Stdin))
+		fmt.Println()
+		if err != nil {
+			color.Red("Read password failed: %v", err)
+			return
+		}
+		fmt.Print("Retype Password: ")
+		second, err := term.ReadPassword(int(syscall.Stdin))
+		fmt.Println()
+		if err != nil {
+			color.Red("Read password failed: %v", err)
+			return
+		}
+		if string(first) != string(second) {
+			color.Red("Passwords do not match")
+			return
+		}
+		password = string(first)
+	}
+
+	if password == "" {
+		color.Red("Password cannot be empty")
+		return
+	}
+
+	nodes, results, err := runOnGroup(users.PasswordCommand(name, password))
+	if err != nil {
+		color.Red("Set password failed: %v", err)
+		return
+	}
+	session.DisplayResults(nodes, results, true)
+
+	if usersPasswdGenerate {
+		color.Green("New password for '%s': %s", name, password)
+	}
+}
@@ -0,0 +1,57 @@
+package users
+
+import (
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/logic/users"
+	"zhaowanpeng/cluster-manager/internal/session"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var (
+	usersSudoRule   string
+	usersSudoRemove bool
+)
+
+var usersSudoCmd = &cobra.Command{
+	Use:   "sudo <user>",
+	Short: "管理账户的 sudoers 配置",
+	Long:  "在 /etc/sudoers.d 下为账户写入或移除独立的配置片段，写入前使用 visudo 校验",
+	Example: `  talko users sudo deploy -g web --rule "ALL=(ALL) NOPASSWD: ALL"
+  talko users sudo deploy -g web --remove`,
+	Args: cobra.ExactArgs(1),
+	Run:  usersSudoFunc,
+}
+
+func init() {
+	usersSudoCmd.Flags().StringVar(&usersSudoRule, "rule", "ALL=(ALL) ALL", "sudo 规则（不含用户名部分）")
+	usersSudoCmd.Flags().BoolVar(&usersSudoRemove, "remove", false, "移除 sudoers 配置")
+}
+
+func usersSudoFunc(cmd *cobra.Command, args []string) {
+	name := args[0]
+	if err := users.ValidateName(name); err != nil {
+		color.Red("%v", err)
+		return
+	}
+
+	var command string
+	if usersSudoRemove {
+		command = users.RemoveSudoCommand(name)
+	} else {
+		rule := strings.TrimSpace(usersSudoRule)
+		if rule == "" || strings.Contains(rule, "\n") {
+			color.Red("Invalid sudo rule")
+			return
+		}
+		command = users.SudoCommand(name, rule)
+	}
+
+	nodes, results, err := runOnGroup(command)
+	if err != nil {
+		color.Red("Update sudoers failed: %v", err)
+		return
+	}
+	session.DisplayResults(nodes, results, true)
+}
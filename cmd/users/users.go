@@ -0,0 +1,66 @@
+package users
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	usersGroupName string
+	usersTimeout   int
+	usersFanout    int
+	usersDialRate  float64
+)
+
+var UsersCmd = &cobra.Command{
+	Use:   "users",
+	Short: "集群账户管理",
+	Long:  "在组内所有节点上创建、删除账户，设置密码、附加组和 sudo 权限，并检查账户漂移",
+}
+
+func init() {
+	UsersCmd.PersistentFlags().StringVarP(&usersGroupName, "group", "g", "", "组名称")
+	UsersCmd.PersistentFlags().IntVarP(&usersTimeout, "timeout", "t", 30, "命令执行超时时间（秒）")
+	UsersCmd.PersistentFlags().IntVar(&usersFanout, "fanout", 0, "并发处理的节点数上限，默认使用组配置")
+	UsersCmd.PersistentFlags().Float64Var(&usersDialRate, "rate", 0, "每秒新建连接数上限，默认使用组配置")
+
+	UsersCmd.AddCommand(usersCreateCmd)
+	UsersCmd.AddCommand(usersDeleteCmd)
+	UsersCmd.AddCommand(usersPasswdCmd)
+	UsersCmd.AddCommand(usersGroupsCmd)
+	UsersCmd.AddCommand(usersSudoCmd)
+	UsersCmd.AddCommand(usersDriftCmd)
+}
+
+// runOnGroup 在组内所有节点上执行命令
+func runOnGroup(command string) ([]model.Node, map[string]session.ExecResult, error) {
+	if usersGroupName == "" {
+		return nil, nil, fmt.Errorf("请使用 -g 指定组名称")
+	}
+
+	group, err := crud.GetGroup(usersGroupName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取组信息失败: %v", err)
+	}
+
+	nodes, err := crud.GetNodesInGroup(usersGroupName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取组节点失败: %v", err)
+	}
+	if len(nodes) == 0 {
+		return nil, nil, fmt.Errorf("组 '%s' 中没有节点", usersGroupName)
+	}
+
+	sessionManager := session.NewSessionManager()
+	sessionManager.SetLimiter(fanout.ForGroup(group, usersFanout, usersDialRate))
+	defer sessionManager.CloseAll()
+
+	results := sessionManager.RunCommand(nodes, command, time.Duration(usersTimeout)*time.Second)
+	return nodes, results, nil
+}
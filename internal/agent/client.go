@@ -0,0 +1,215 @@
+package agent
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	sshagent "golang.org/x/crypto/ssh/agent"
+)
+
+// uname -m 输出与 GOARCH 的对应关系
+var unameArch = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"arm64":   "arm64",
+	"i686":    "386",
+	"i386":    "386",
+}
+
+var (
+	selfOnce sync.Once
+	selfPath string
+	selfHash string
+	selfErr  error
+)
+
+// self 返回本程序的路径和内容摘要
+func self() (string, string, error) {
+	selfOnce.Do(func() {
+		selfPath, selfErr = os.Executable()
+		if selfErr != nil {
+			return
+		}
+		f, err := os.Open(selfPath)
+		if err != nil {
+			selfErr = err
+			return
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil {
+			selfErr = err
+			return
+		}
+		selfHash = hex.EncodeToString(h.Sum(nil))
+	})
+	return selfPath, selfHash, selfErr
+}
+
+// 网关上存放代理程序的目录，位于登录用户的主目录下，其他用户无法写入
+const remoteDir = `"$HOME/.cache/talko"`
+
+// Ship 将本程序上传到网关节点，内容相同的程序只上传一次
+// 已有的程序只有在目录和文件都属于当前用户、不是符号链接且摘要与本程序一致时才会复用，否则重新上传
+// 返回远程程序路径，路径中的 $HOME 由网关的 shell 展开
+func Ship(client *ssh.Client) (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("代理模式只支持从 Linux 分发，当前系统: %s", runtime.GOOS)
+	}
+
+	localPath, hash, err := self()
+	if err != nil {
+		return "", fmt.Errorf("读取本程序失败: %v", err)
+	}
+	remotePath := fmt.Sprintf(`%s/"agent-%s"`, remoteDir, hash[:16])
+
+	// 检查远程架构，以及已上传程序的属主和摘要
+	check := fmt.Sprintf("uname -sm; d=%s; f=%s; "+
+		`if [ -d "$d" ] && [ ! -L "$d" ] && [ -O "$d" ] && [ -f "$f" ] && [ ! -L "$f" ] && [ -O "$f" ] && [ -x "$f" ]; then `+
+		`sha256sum "$f" 2>/dev/null | cut -d' ' -f1; fi`, remoteDir, remotePath)
+	out, err := run(client, check, nil)
+	if err != nil {
+		return "", fmt.Errorf("检查网关环境失败: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) != 2 || fields[0] != "Linux" || unameArch[fields[1]] != runtime.GOARCH {
+		return "", fmt.Errorf("网关平台 %q 与本程序 linux/%s 不匹配", lines[0], runtime.GOARCH)
+	}
+	if len(lines) > 1 && strings.TrimSpace(lines[len(lines)-1]) == hash {
+		return remotePath, nil
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// 目录权限为 0700 且必须属于当前用户；上传到临时文件后改名，避免并发上传时执行到不完整的文件
+	upload := fmt.Sprintf("umask 077; d=%s; f=%s; t=\"$f.%d\"; "+
+		`mkdir -p "$d" && [ -d "$d" ] && [ ! -L "$d" ] && [ -O "$d" ] && chmod 700 "$d" || { echo "目录 $d 不属于当前用户" >&2; exit 1; }; `+
+		`rm -f "$t" && cat > "$t" && chmod 700 "$t" && mv -f "$t" "$f"`, remoteDir, remotePath, time.Now().UnixNano())
+	if _, err := run(client, upload, f); err != nil {
+		return "", fmt.Errorf("上传代理程序失败: %v", err)
+	}
+
+	return remotePath, nil
+}
+
+// run 在新的 exec 通道中执行命令
+func run(client *ssh.Client, command string, stdin io.Reader) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	out, err := session.CombinedOutput(command)
+	if err != nil {
+		return string(out), fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// Conn 是与网关代理之间的长连接，同一时间只处理一条请求
+type Conn struct {
+	mu      sync.Mutex
+	session *ssh.Session
+	stdin   io.WriteCloser
+	enc     *json.Encoder
+	scanner *bufio.Scanner
+}
+
+// Start 在网关上启动代理进程
+// forwardAgent 为 true 且本地设置了 SSH_AUTH_SOCK 时把本地 SSH agent 转发给代理，代理用它连接目标节点
+// 转发失败不影响启动，代理仍可使用网关上用户自己的私钥
+func Start(client *ssh.Client, remotePath string, forwardAgent bool) (*Conn, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); forwardAgent && sock != "" {
+		// 同一连接上重启代理时转发通道已经注册过，忽略该错误
+		sshagent.ForwardToRemote(client, sock)
+		sshagent.RequestAgentForwarding(session)
+	}
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+
+	if err := session.Start(remotePath + " agent"); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("启动代理失败: %v", err)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+
+	return &Conn{
+		session: session,
+		stdin:   stdin,
+		enc:     json.NewEncoder(stdin),
+		scanner: scanner,
+	}, nil
+}
+
+// ErrNotSent 表示请求没有发送给代理，目标节点上的命令一定没有执行
+var ErrNotSent = errors.New("请求未发送")
+
+// Exec 发送请求并逐个接收节点结果，所有结果返回后结束
+// 发送请求失败时返回的错误包装了 ErrNotSent，其余错误发生时命令可能已经在部分节点上执行
+func (c *Conn) Exec(req Request, onResponse func(Response)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.enc.Encode(req); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotSent, err)
+	}
+
+	for c.scanner.Scan() {
+		var resp Response
+		if err := json.Unmarshal(c.scanner.Bytes(), &resp); err != nil {
+			return fmt.Errorf("解析代理响应失败: %v", err)
+		}
+		if resp.ID != req.ID {
+			continue
+		}
+		if resp.Done {
+			return nil
+		}
+		onResponse(resp)
+	}
+
+	if err := c.scanner.Err(); err != nil {
+		return fmt.Errorf("读取代理响应失败: %v", err)
+	}
+	return fmt.Errorf("代理连接已断开")
+}
+
+// Close 关闭代理进程
+func (c *Conn) Close() {
+	c.stdin.Close()
+	c.session.Close()
+}
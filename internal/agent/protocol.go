@@ -0,0 +1,43 @@
+package agent
+
+// Request 是客户端发给网关代理的一条执行请求
+// 代理按行读取 JSON 编码的请求，同一连接上可以连续发送多条
+type Request struct {
+	ID      string `json:"id"`
+	Command string `json:"command"`
+	Timeout int    `json:"timeout"` // 秒
+	Nodes   []Node `json:"nodes"`
+	// 网关上的并发和建连速率限制，与客户端的设置保持一致
+	Fanout   int     `json:"fanout,omitempty"`
+	DialRate float64 `json:"dial_rate,omitempty"`
+	// NoPTY 不分配伪终端执行，分别返回标准输出和标准错误
+	NoPTY bool `json:"no_pty,omitempty"`
+}
+
+// Node 是代理需要连接的目标节点
+type Node struct {
+	IP       string `json:"ip"`
+	Port     int    `json:"port"`
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+	Key      string `json:"key,omitempty"` // PEM 格式私钥内容
+}
+
+// Response 是代理返回的单个节点结果，每个节点完成后立即返回
+// 一条请求的所有节点结果之后跟随一条 Done 为 true 的响应
+type Response struct {
+	ID      string `json:"id"`
+	IP      string `json:"ip,omitempty"`
+	Output  string `json:"output,omitempty"`
+	Stderr  string `json:"stderr,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Success bool   `json:"success"`
+	Done    bool   `json:"done,omitempty"`
+
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal,omitempty"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Duration int64  `json:"duration_ms,omitempty"` // 毫秒
+	Cwd      string `json:"cwd,omitempty"`
+}
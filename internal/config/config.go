@@ -0,0 +1,61 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// Config 是应用数据目录下 config.json 中的用户配置
+// 文件不存在时使用零值配置
+type Config struct {
+	Merge MergeConfig `json:"merge"`
+}
+
+// MergeConfig 是合并输出相关的配置
+type MergeConfig struct {
+	// Normalize 为 true 时合并输出默认先归一化再分组
+	Normalize bool `json:"normalize"`
+	// Rules 用户自定义的归一化规则，匹配的部分在分组时被屏蔽
+	Rules []NormalizeRule `json:"rules"`
+	// DisableBuiltins 关闭的内置规则: ip, hostname, uuid, timestamp, number
+	DisableBuiltins []string `json:"disable_builtins"`
+}
+
+// NormalizeRule 是一条用户自定义的归一化规则
+type NormalizeRule struct {
+	Name    string `json:"name"`    // 规则名，显示为屏蔽后的占位符
+	Pattern string `json:"pattern"` // Go 正则表达式
+}
+
+// Path 返回配置文件路径
+func Path() (string, error) {
+	appDir, err := model.AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, "config.json"), nil
+}
+
+// Load 读取配置文件，文件不存在时返回零值配置
+func Load() (*Config, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,60 @@
+package crud
+
+import (
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+
+	"gorm.io/gorm"
+)
+
+// SaveNodeCheck 保存节点检查结果，并更新节点的可用状态和最近检查时间
+func SaveNodeCheck(check model.NodeCheck) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&check).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Node{}).Where("id = ?", check.NodeID).
+			Updates(map[string]interface{}{"usable": check.Usable, "last_check_at": check.CheckedAt}).Error
+	})
+}
+
+// ListNodeChecks 按检查时间从旧到新列出组内节点在 since 之后的检查结果
+func ListNodeChecks(groupName string, since time.Time) ([]model.NodeCheck, error) {
+	var checks []model.NodeCheck
+	err := model.DB.Where("group_name = ? AND checked_at >= ?", groupName, since).
+		Order("checked_at").Find(&checks).Error
+	if err != nil {
+		return nil, err
+	}
+	return checks, nil
+}
+
+// PruneNodeChecks 删除早于 before 的检查结果，返回删除的条数
+func PruneNodeChecks(before time.Time) (int64, error) {
+	result := model.DB.Where("checked_at < ?", before).Delete(&model.NodeCheck{})
+	return result.RowsAffected, result.Error
+}
+
+// LatestNodeChecks 返回每个节点最近一次的检查结果，以节点ID为键
+// usableOnly 为 true 时返回最近一次可用的检查结果
+func LatestNodeChecks(usableOnly bool) (map[string]model.NodeCheck, error) {
+	latest := model.DB.Model(&model.NodeCheck{}).Select("node_id, MAX(checked_at) AS checked_at").Group("node_id")
+	if usableOnly {
+		latest = latest.Where("usable = ?", true)
+	}
+	query := model.DB.Table("node_checks AS c").Select("c.*").
+		Joins("JOIN (?) AS l ON c.node_id = l.node_id AND c.checked_at = l.checked_at", latest)
+	if usableOnly {
+		query = query.Where("c.usable = ?", true)
+	}
+
+	var checks []model.NodeCheck
+	if err := query.Find(&checks).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[string]model.NodeCheck, len(checks))
+	for _, check := range checks {
+		result[check.NodeID] = check
+	}
+	return result, nil
+}
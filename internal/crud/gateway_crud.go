@@ -0,0 +1,38 @@
+package crud
+
+import (
+	"fmt"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// SetGateway 设置组内子网的网关节点，sendCredentials 表示是否把目标节点的凭据发给网关
+func SetGateway(groupName, subnet, nodeID string, sendCredentials bool) error {
+	gateway := model.Gateway{
+		ID:              fmt.Sprintf("%s-%s", groupName, subnet),
+		GroupName:       groupName,
+		Subnet:          subnet,
+		NodeID:          nodeID,
+		SendCredentials: sendCredentials,
+	}
+	return model.DB.Save(&gateway).Error
+}
+
+// RemoveGateway 移除组内子网的网关，subnet 为空时移除组的所有网关
+func RemoveGateway(groupName, subnet string) (int64, error) {
+	query := model.DB.Where("group_name = ?", groupName)
+	if subnet != "" {
+		query = query.Where("subnet = ?", subnet)
+	}
+	result := query.Delete(&model.Gateway{})
+	return result.RowsAffected, result.Error
+}
+
+// ListGateways 列出组的所有网关
+func ListGateways(groupName string) ([]model.Gateway, error) {
+	var gateways []model.Gateway
+	result := model.DB.Where("group_name = ?", groupName).Order("subnet").Find(&gateways)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return gateways, nil
+}
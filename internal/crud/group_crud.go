@@ -81,3 +81,34 @@ func GetGroup(name string) (model.Group, error) {
 
 	return group, nil
 }
+
+// SetGroupJumpHosts 设置组的跳板机链
+func SetGroupJumpHosts(name, jumpHosts string) error {
+	result := model.DB.Model(&model.Group{}).Where("`name` = ?", name).Updates(map[string]interface{}{
+		"jump_hosts": jumpHosts,
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("group '%s' not found", name)
+	}
+	return nil
+}
+
+// SetGroupLimits 设置组的并发和建连速率限制，0 表示使用默认值
+func SetGroupLimits(name string, fanout int, dialRate float64) error {
+	result := model.DB.Model(&model.Group{}).Where("`name` = ?", name).Updates(map[string]interface{}{
+		"fanout":     fanout,
+		"dial_rate":  dialRate,
+		"updated_at": time.Now(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("group '%s' not found", name)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package crud
+
+import (
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+
+	"gorm.io/gorm"
+)
+
+// PruneStats 记录清理历史时删除的记录数
+type PruneStats struct {
+	Sessions int64
+	Commands int64
+	Outputs  int64
+}
+
+// PruneHistory 清理历史会话及其命令和输出
+// before 为零值时不按时间过滤；keepSessions 表示无论多旧都保留的最近会话数
+func PruneHistory(before time.Time, keepSessions int) (PruneStats, error) {
+	var stats PruneStats
+
+	err := model.DB.Transaction(func(tx *gorm.DB) error {
+		// 最近的 keepSessions 个会话始终保留
+		var keepIDs []string
+		if keepSessions > 0 {
+			if err := tx.Model(&model.Session{}).Order("start_time desc").
+				Limit(keepSessions).Pluck("id", &keepIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		query := tx.Model(&model.Session{})
+		if !before.IsZero() {
+			query = query.Where("start_time < ?", before)
+		}
+		if len(keepIDs) > 0 {
+			query = query.Where("id NOT IN ?", keepIDs)
+		}
+
+		var sessionIDs []string
+		if err := query.Pluck("id", &sessionIDs).Error; err != nil {
+			return err
+		}
+		if len(sessionIDs) == 0 {
+			return nil
+		}
+
+		var commandIDs []string
+		if err := tx.Model(&model.Command{}).Where("session_id IN ?", sessionIDs).
+			Pluck("id", &commandIDs).Error; err != nil {
+			return err
+		}
+
+		// SQLite 对单条语句的参数个数有限制，分批删除
+		for _, batch := range chunkStrings(commandIDs, 500) {
+			result := tx.Where("command_id IN ?", batch).Delete(&model.CommandOutput{})
+			if result.Error != nil {
+				return result.Error
+			}
+			stats.Outputs += result.RowsAffected
+		}
+
+		for _, batch := range chunkStrings(sessionIDs, 500) {
+			result := tx.Where("session_id IN ?", batch).Delete(&model.Command{})
+			if result.Error != nil {
+				return result.Error
+			}
+			stats.Commands += result.RowsAffected
+
+			result = tx.Where("id IN ?", batch).Delete(&model.Session{})
+			if result.Error != nil {
+				return result.Error
+			}
+			stats.Sessions += result.RowsAffected
+		}
+
+		return nil
+	})
+
+	return stats, err
+}
+
+// CompactDB 回收已删除记录占用的磁盘空间
+func CompactDB() error {
+	return model.DB.Exec("VACUUM").Error
+}
+
+// chunkStrings 将切片按指定大小分批
+func chunkStrings(items []string, size int) [][]string {
+	var chunks [][]string
+	for size < len(items) {
+		items, chunks = items[size:], append(chunks, items[:size])
+	}
+	if len(items) > 0 {
+		chunks = append(chunks, items)
+	}
+	return chunks
+}
+
+// RecentCommands 返回在指定组上执行过的命令，按最近执行时间排列并去重
+func RecentCommands(groupName string, limit int) ([]string, error) {
+	var commands []string
+	err := model.DB.Model(&model.Command{}).
+		Joins("JOIN sessions ON sessions.id = commands.session_id").
+		Where("sessions.group_name = ?", groupName).
+		Group("commands.command").
+		Order("MAX(commands.exec_time) desc").
+		Limit(limit).
+		Pluck("commands.command", &commands).Error
+	return commands, err
+}
+
+// CommandCount 是组内一个节点上记录的命令执行成功和失败次数，超时或退出码非 0 计为失败
+type CommandCount struct {
+	GroupName string
+	NodeIP    string
+	Success   int64
+	Failure   int64
+}
+
+// CountNodeCommands 按组和节点统计历史记录中的命令执行结果
+func CountNodeCommands() ([]CommandCount, error) {
+	return countCommands(model.DB)
+}
+
+// CountSessionCommands 按节点统计一个会话中的命令执行结果
+func CountSessionCommands(sessionID string) ([]CommandCount, error) {
+	return countCommands(model.DB.Where("commands.session_id = ?", sessionID))
+}
+
+// countCommands 按组和节点汇总 db 条件下的命令输出
+func countCommands(db *gorm.DB) ([]CommandCount, error) {
+	var counts []CommandCount
+	err := db.Model(&model.CommandOutput{}).
+		Select("sessions.group_name AS group_name, command_outputs.node_ip AS node_ip, "+
+			"SUM(CASE WHEN command_outputs.exit_code = 0 AND command_outputs.timed_out = ? THEN 1 ELSE 0 END) AS success, "+
+			"SUM(CASE WHEN command_outputs.exit_code = 0 AND command_outputs.timed_out = ? THEN 0 ELSE 1 END) AS failure", false, false).
+		Joins("JOIN commands ON commands.id = command_outputs.command_id").
+		Joins("JOIN sessions ON sessions.id = commands.session_id").
+		Group("sessions.group_name, command_outputs.node_ip").
+		Order("sessions.group_name, command_outputs.node_ip").
+		Scan(&counts).Error
+	return counts, err
+}
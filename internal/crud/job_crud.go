@@ -0,0 +1,80 @@
+package crud
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+
+	"gorm.io/gorm"
+)
+
+// CreateJob 保存作业及其在各节点上的进程
+func CreateJob(job model.Job, nodes []model.JobNode) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(nodes, 100).Error
+	})
+}
+
+// GetJob 获取作业
+func GetJob(id string) (model.Job, error) {
+	var job model.Job
+	result := model.DB.Where("id = ?", id).Limit(1).Find(&job)
+	if result.Error != nil {
+		return job, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return job, fmt.Errorf("作业 '%s' 不存在", id)
+	}
+	return job, nil
+}
+
+// ListJobs 按启动时间从新到旧列出作业，limit 不大于 0 时不限制数量
+func ListJobs(limit int) ([]model.Job, error) {
+	var jobs []model.Job
+	query := model.DB.Order("start_time desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// GetJobNodes 获取作业在各节点上的进程
+func GetJobNodes(jobID string) ([]model.JobNode, error) {
+	var nodes []model.JobNode
+	if err := model.DB.Where("job_id = ?", jobID).Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// UpdateJobNodeState 保存最近一次查询到的节点状态
+func UpdateJobNodeState(id, state string, checkedAt time.Time) error {
+	return model.DB.Model(&model.JobNode{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"state": state, "checked_at": checkedAt}).Error
+}
+
+// DeleteJob 删除作业及其节点记录
+func DeleteJob(id string) error {
+	return model.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", id).Delete(&model.JobNode{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&model.Job{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("作业 '%s' 不存在", id)
+		}
+		return nil
+	})
+}
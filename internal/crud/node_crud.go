@@ -2,10 +2,10 @@ package crud
 
 import (
 	"fmt"
-	"sync"
 	"time"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
 	"zhaowanpeng/cluster-manager/internal/types"
-	"zhaowanpeng/cluster-manager/internal/utils"
 	"zhaowanpeng/cluster-manager/model"
 )
 
@@ -29,8 +29,18 @@ func GetNodesInGroup(groupName string) ([]model.Node, error) {
 	return nodes, nil
 }
 
+// ListNodes 按组和IP列出所有节点
+func ListNodes() ([]model.Node, error) {
+	var nodes []model.Node
+	if err := model.DB.Order("`group`, ip").Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
 // AddNodesToGroup 添加节点到组
-func AddOrUpdateNodes(groupName string, ips []string, port int, user, password, description string) ([]types.Result, error) {
+// limiter 限制同时验证的节点数和新建连接速率，为 nil 时不限制
+func AddOrUpdateNodes(groupName string, ips []string, port int, user, password, description string, limiter *fanout.Limiter) ([]types.Result, error) {
 	// 检查组是否存在
 	var group model.Group
 	result := model.DB.Where("`name` = ?", groupName).First(&group)
@@ -38,92 +48,88 @@ func AddOrUpdateNodes(groupName string, ips []string, port int, user, password,
 		return nil, fmt.Errorf("group '%s' not found", groupName)
 	}
 
-	var wg sync.WaitGroup
 	// 结果集通道, make中len(ips)代表最大容量
 	resultChan := make(chan types.Result, len(ips))
 
 	// 结果集, make中0代表初始容量, len(ips)代表最大容量
 	results := make([]types.Result, 0, len(ips))
 
-	// 为每个 IP 创建一个 goroutine
-	for _, ip := range ips {
-		wg.Add(1)
-		go func(ip string) {
-			defer wg.Done()
-
-			// 检查节点是否已存在
-			var existingNode model.Node
-			result := model.DB.Where("`group` = ? AND ip = ?", groupName, ip).First(&existingNode)
-
-			now := time.Now()
-
-			// 检查 SSH 连接
-			sshClient, status := utils.SSH_Check(
-				ip,
-				port,
-				user,
-				password,
-				30*time.Second,
-			)
-
-			isConnected := sshClient != nil
-			if sshClient != nil {
-				sshClient.Close() // 确保关闭客户端
+	// 以受限的并发度逐个验证 IP
+	limiter.Run(len(ips), func(i int) {
+		ip := ips[i]
+
+		// 检查节点是否已存在
+		var existingNode model.Node
+		result := model.DB.Where("`group` = ? AND ip = ?", groupName, ip).First(&existingNode)
+
+		now := time.Now()
+
+		// 检查 SSH 连接，已存在的节点沿用其跳板机配置，否则使用组的配置
+		candidate := model.Node{
+			ID:       fmt.Sprintf("%s-%s", groupName, ip),
+			IP:       ip,
+			Port:     port,
+			User:     user,
+			Password: password,
+			Group:    groupName,
+		}
+		if result.RowsAffected > 0 {
+			candidate.ID = existingNode.ID
+			candidate.JumpHosts = existingNode.JumpHosts
+		}
+		limiter.WaitDial()
+		isConnected, status := sshconn.Check(candidate, 30*time.Second)
+
+		// 如果节点已存在，更新它
+		if result.RowsAffected > 0 {
+			existingNode.Password = password
+			existingNode.LastCheckAt = now
+			existingNode.Usable = isConnected
+			existingNode.Description = description
+
+			model.DB.Save(&existingNode)
+
+			resultChan <- types.Result{
+				IP:      ip,
+				Msg:     status,
+				Success: isConnected,
 			}
-
-			// 如果节点已存在，更新它
-			if result.RowsAffected > 0 {
-				existingNode.Password = password
-				existingNode.LastCheckAt = now
-				existingNode.Usable = isConnected
-				existingNode.Description = description
-
-				model.DB.Save(&existingNode)
-
-				resultChan <- types.Result{
-					IP:      ip,
-					Msg:     status,
-					Success: isConnected,
-				}
-				return
+			return
+		}
+
+		// 创建新节点
+		newNode := model.Node{
+			ID:          fmt.Sprintf("%s-%s", groupName, ip),
+			IP:          ip,
+			Port:        port,
+			User:        user,
+			Password:    password,
+			Group:       groupName,
+			AddAt:       now,
+			LastCheckAt: now,
+			Usable:      isConnected,
+			Description: description,
+		}
+
+		result = model.DB.Create(&newNode)
+
+		if result.Error != nil {
+			resultChan <- types.Result{
+				IP:      ip,
+				Msg:     fmt.Sprintf("Database error: %v", result.Error),
+				Success: false,
 			}
 
-			// 创建新节点
-			newNode := model.Node{
-				ID:          fmt.Sprintf("%s-%s", groupName, ip),
-				IP:          ip,
-				Port:        port,
-				User:        user,
-				Password:    password,
-				Group:       groupName,
-				AddAt:       now,
-				LastCheckAt: now,
-				Usable:      isConnected,
-				Description: description,
+		} else {
+			resultChan <- types.Result{
+				IP:      ip,
+				Msg:     status,
+				Success: true,
 			}
+		}
 
-			result = model.DB.Create(&newNode)
-
-			if result.Error != nil {
-				resultChan <- types.Result{
-					IP:      ip,
-					Msg:     fmt.Sprintf("Database error: %v", result.Error),
-					Success: false,
-				}
-
-			} else {
-				resultChan <- types.Result{
-					IP:      ip,
-					Msg:     status,
-					Success: true,
-				}
-			}
-
-		}(ip)
-	}
+	})
 
-	// 等待所有 goroutine 完成
-	wg.Wait()
 	close(resultChan)
 
 	// 收集结果
@@ -133,3 +139,112 @@ func AddOrUpdateNodes(groupName string, ips []string, port int, user, password,
 
 	return results, nil
 }
+
+// SwitchNodeToKey 将节点切换为密钥认证并清除保存的密码
+func SwitchNodeToKey(nodeID, keyPath string) error {
+	result := model.DB.Model(&model.Node{}).Where("id = ?", nodeID).Updates(map[string]interface{}{
+		"auth_type": model.AuthKey,
+		"key_path":  keyPath,
+		"password":  "",
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("node '%s' not found", nodeID)
+	}
+	return nil
+}
+
+// UpdateNodePassword 更新节点保存的密码，调用方需确保新密码已验证可以登录
+func UpdateNodePassword(nodeID, password string) error {
+	result := model.DB.Model(&model.Node{}).Where("id = ?", nodeID).Updates(map[string]interface{}{
+		"password":      password,
+		"last_check_at": time.Now(),
+		"usable":        true,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("node '%s' not found", nodeID)
+	}
+	return nil
+}
+
+// GetNode 按ID获取节点
+func GetNode(id string) (model.Node, error) {
+	var node model.Node
+	result := model.DB.Where("id = ?", id).Limit(1).Find(&node)
+	if result.Error != nil {
+		return node, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return node, fmt.Errorf("node '%s' not found", id)
+	}
+	return node, nil
+}
+
+// ResolveNodeRef 将节点ID或IP解析为节点，IP对应多个节点时要求使用ID
+func ResolveNodeRef(ref string) (model.Node, error) {
+	if node, err := GetNode(ref); err == nil {
+		return node, nil
+	}
+
+	var nodes []model.Node
+	if err := model.DB.Where("ip = ?", ref).Find(&nodes).Error; err != nil {
+		return model.Node{}, err
+	}
+	switch len(nodes) {
+	case 0:
+		return model.Node{}, fmt.Errorf("node '%s' not found", ref)
+	case 1:
+		return nodes[0], nil
+	default:
+		return model.Node{}, fmt.Errorf("IP '%s' matches %d nodes, please use node ID", ref, len(nodes))
+	}
+}
+
+// SetNodesJumpHosts 设置组内指定节点的跳板机，ips 为空时作用于组内所有节点
+func SetNodesJumpHosts(groupName string, ips []string, jumpHosts string) (int64, error) {
+	query := model.DB.Model(&model.Node{}).Where("`group` = ?", groupName)
+	if len(ips) > 0 {
+		query = query.Where("ip IN ?", ips)
+	}
+	result := query.Update("jump_hosts", jumpHosts)
+	return result.RowsAffected, result.Error
+}
+
+// CopyNodesToGroup 将节点复制到指定组，保留连接信息，组内已有相同IP的节点时跳过
+func CopyNodesToGroup(groupName string, nodes []model.Node) (int, error) {
+	if _, err := GetGroup(groupName); err != nil {
+		return 0, err
+	}
+
+	copied := 0
+	now := time.Now()
+	for _, node := range nodes {
+		var count int64
+		if err := model.DB.Model(&model.Node{}).Where("`group` = ? AND ip = ?", groupName, node.IP).Count(&count).Error; err != nil {
+			return copied, err
+		}
+		if count > 0 {
+			continue
+		}
+
+		node.ID = fmt.Sprintf("%s-%s", groupName, node.IP)
+		node.Group = groupName
+		node.AddAt = now
+		if err := model.DB.Create(&node).Error; err != nil {
+			return copied, err
+		}
+		copied++
+	}
+	return copied, nil
+}
+
+// UpdateNodeFacts 保存节点采集到的信息
+func UpdateNodeFacts(nodeID, facts string, collectedAt time.Time) error {
+	return model.DB.Model(&model.Node{}).Where("id = ?", nodeID).
+		Updates(map[string]interface{}{"facts": facts, "facts_at": collectedAt}).Error
+}
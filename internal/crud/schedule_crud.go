@@ -0,0 +1,62 @@
+package crud
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// CreateSchedule 保存定时任务，名称已存在时返回错误
+func CreateSchedule(schedule model.Schedule) error {
+	if _, err := GetSchedule(schedule.Name); err == nil {
+		return fmt.Errorf("定时任务 '%s' 已存在", schedule.Name)
+	}
+	return model.DB.Create(&schedule).Error
+}
+
+// GetSchedule 获取定时任务
+func GetSchedule(name string) (model.Schedule, error) {
+	var schedule model.Schedule
+	result := model.DB.Where("name = ?", name).Limit(1).Find(&schedule)
+	if result.Error != nil {
+		return schedule, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return schedule, fmt.Errorf("定时任务 '%s' 不存在", name)
+	}
+	return schedule, nil
+}
+
+// ListSchedules 按名称列出所有定时任务
+func ListSchedules() ([]model.Schedule, error) {
+	var schedules []model.Schedule
+	if err := model.DB.Order("name").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// DeleteSchedule 删除定时任务
+func DeleteSchedule(name string) error {
+	result := model.DB.Where("name = ?", name).Delete(&model.Schedule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("定时任务 '%s' 不存在", name)
+	}
+	return nil
+}
+
+// UpdateScheduleRun 保存定时任务最近一次执行的时间、会话和合并结果，changed 为 true 时同时更新变化时间
+func UpdateScheduleRun(name string, runAt time.Time, sessionID, summary string, changed bool) error {
+	updates := map[string]interface{}{
+		"last_run_at":     runAt,
+		"last_session_id": sessionID,
+		"last_summary":    summary,
+	}
+	if changed {
+		updates["last_changed_at"] = runAt
+	}
+	return model.DB.Model(&model.Schedule{}).Where("name = ?", name).Updates(updates).Error
+}
@@ -0,0 +1,141 @@
+package facts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/types"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// Script 在节点上以 key=value 的形式逐行输出系统信息，只依赖 POSIX shell 和 /proc
+// 列表类的信息（磁盘、IP）每项一行
+const Script = `PRETTY_NAME= ID= VERSION_ID=; [ -r /etc/os-release ] && . /etc/os-release
+echo "hostname=$(hostname 2>/dev/null || cat /proc/sys/kernel/hostname)"
+echo "os=$PRETTY_NAME"
+echo "os_id=$ID"
+echo "os_version=$VERSION_ID"
+echo "kernel=$(uname -r)"
+echo "arch=$(uname -m)"
+echo "cpus=$(getconf _NPROCESSORS_ONLN 2>/dev/null || grep -c ^processor /proc/cpuinfo)"
+echo "memory_kb=$(awk '/^MemTotal:/ {print $2}' /proc/meminfo 2>/dev/null)"
+df -P -k 2>/dev/null | awk 'NR > 1 && $1 ~ /^\/dev\// {print "disk=" $2 " " $3 " " $6}'
+if command -v ip >/dev/null 2>&1; then
+	ip -o addr show scope global 2>/dev/null | awk '{split($4, a, "/"); print "ip=" a[1]}'
+else
+	for a in $(hostname -I 2>/dev/null); do echo "ip=$a"; done
+fi
+echo "uptime=$(cut -d. -f1 /proc/uptime 2>/dev/null)"
+v=$(systemd-detect-virt 2>/dev/null)
+if [ -z "$v" ]; then
+	if [ -f /.dockerenv ]; then v=docker
+	elif grep -qa container=lxc /proc/1/environ 2>/dev/null; then v=lxc
+	elif grep -q '^flags.* hypervisor' /proc/cpuinfo 2>/dev/null; then v=vm
+	else v=none; fi
+fi
+echo "virtualization=$v"`
+
+// Parse 解析采集脚本的输出
+func Parse(output string) types.Facts {
+	var f types.Facts
+	for _, line := range strings.Split(output, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "hostname":
+			f.Hostname = value
+		case "os":
+			f.OS = value
+		case "os_id":
+			f.OSID = value
+		case "os_version":
+			f.OSVersion = value
+		case "kernel":
+			f.Kernel = value
+		case "arch":
+			f.Arch = value
+		case "cpus":
+			f.CPUs, _ = strconv.Atoi(value)
+		case "memory_kb":
+			kb, _ := strconv.ParseInt(value, 10, 64)
+			f.MemoryMB = kb / 1024
+		case "disk":
+			// 总大小(KB) 已用(KB) 挂载点，挂载点可能包含空格
+			fields := strings.SplitN(value, " ", 3)
+			if len(fields) != 3 {
+				continue
+			}
+			size, _ := strconv.ParseInt(fields[0], 10, 64)
+			used, _ := strconv.ParseInt(fields[1], 10, 64)
+			f.Disks = append(f.Disks, types.Disk{Mount: fields[2], SizeMB: size / 1024, UsedMB: used / 1024})
+		case "ip":
+			if value != "" {
+				f.IPs = append(f.IPs, value)
+			}
+		case "uptime":
+			f.Uptime, _ = strconv.ParseInt(value, 10, 64)
+		case "virtualization":
+			f.Virtualization = value
+		}
+	}
+	return f
+}
+
+// Save 解析采集脚本的输出并保存到节点
+func Save(node model.Node, output string) (types.Facts, error) {
+	f := Parse(output)
+	data, _ := json.Marshal(f)
+	if err := crud.UpdateNodeFacts(node.ID, string(data), time.Now()); err != nil {
+		return f, fmt.Errorf("保存失败: %v", err)
+	}
+	return f, nil
+}
+
+// Load 返回节点保存的系统信息，没有采集过时返回 false
+func Load(node model.Node) (types.Facts, bool) {
+	var f types.Facts
+	if node.Facts == "" {
+		return f, false
+	}
+	if err := json.Unmarshal([]byte(node.Facts), &f); err != nil {
+		return f, false
+	}
+	return f, true
+}
+
+// Summary 返回系统信息的单行摘要
+func Summary(f types.Facts) string {
+	os := f.OS
+	if os == "" {
+		os = "unknown OS"
+	}
+	return fmt.Sprintf("%s, %s %s, %d CPU, %s, %s",
+		os, f.Kernel, f.Arch, f.CPUs, FormatMB(f.MemoryMB), f.Virtualization)
+}
+
+// FormatMB 将以 MB 为单位的容量格式化为便于阅读的形式
+func FormatMB(mb int64) string {
+	switch {
+	case mb >= 1024*1024:
+		return fmt.Sprintf("%.1fT", float64(mb)/(1024*1024))
+	case mb >= 1024:
+		return fmt.Sprintf("%.1fG", float64(mb)/1024)
+	}
+	return fmt.Sprintf("%dM", mb)
+}
+
+// FormatUptime 将运行时长格式化为天和小时
+func FormatUptime(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	days := int64(d / (24 * time.Hour))
+	hours := int64(d % (24 * time.Hour) / time.Hour)
+	if days > 0 {
+		return fmt.Sprintf("%dd%dh", days, hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, int64(d%time.Hour/time.Minute))
+}
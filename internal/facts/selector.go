@@ -0,0 +1,241 @@
+package facts
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/types"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// 选择器支持的比较运算符，按长度从长到短匹配
+var operators = []string{"!=", ">=", "<=", "=", ">", "<", "~"}
+
+// condition 是选择器中的一个条件
+type condition struct {
+	key   string
+	op    string
+	value string
+}
+
+// Selector 按节点保存的系统信息筛选节点，所有条件都满足时匹配
+// 条件形如 os_id=ubuntu、cpus>=8、memory>=16G、kernel~generic，多个条件用逗号分隔
+// = 和 != 不区分大小写并支持通配符；> < 对数字按数值、对版本号按各段数值比较；~ 表示包含
+type Selector struct {
+	conditions []condition
+}
+
+// 选择器可用的字段
+var selectorKeys = map[string]bool{
+	"hostname": true, "os": true, "os_id": true, "os_version": true, "kernel": true, "arch": true,
+	"cpus": true, "memory": true, "ip": true, "uptime": true, "virt": true,
+}
+
+// ParseSelector 解析选择器
+func ParseSelector(expr string) (*Selector, error) {
+	s := &Selector{}
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		i := strings.IndexAny(part, "!=<>~")
+		if i <= 0 {
+			return nil, fmt.Errorf("无效的条件 %q，格式为 字段 运算符 值，如 os_id=ubuntu", part)
+		}
+		c := condition{key: strings.ToLower(strings.TrimSpace(part[:i]))}
+		for _, op := range operators {
+			if strings.HasPrefix(part[i:], op) {
+				c.op = op
+				c.value = strings.TrimSpace(part[i+len(op):])
+				break
+			}
+		}
+		if c.op == "" {
+			return nil, fmt.Errorf("无效的条件 %q", part)
+		}
+		if !selectorKeys[c.key] {
+			return nil, fmt.Errorf("未知的字段 %q，可用字段: hostname, os, os_id, os_version, kernel, arch, cpus, memory, ip, uptime, virt", c.key)
+		}
+
+		// 数值字段的值支持单位，统一换算后比较
+		switch c.key {
+		case "memory":
+			mb, err := parseMB(c.value)
+			if err != nil {
+				return nil, err
+			}
+			c.value = strconv.FormatInt(mb, 10)
+		case "uptime":
+			d, err := utils.ParseDuration(c.value)
+			if err != nil {
+				return nil, err
+			}
+			c.value = strconv.FormatInt(int64(d.Seconds()), 10)
+		}
+		s.conditions = append(s.conditions, c)
+	}
+
+	if len(s.conditions) == 0 {
+		return nil, fmt.Errorf("选择器为空")
+	}
+	return s, nil
+}
+
+// Match 判断系统信息是否满足所有条件
+func (s *Selector) Match(f types.Facts) bool {
+	for _, c := range s.conditions {
+		if !c.match(f) {
+			return false
+		}
+	}
+	return true
+}
+
+// Filter 返回保存的系统信息满足条件的节点，以及因没有采集过而无法判断的节点
+func (s *Selector) Filter(nodes []model.Node) (matched, unknown []model.Node) {
+	for _, node := range nodes {
+		f, ok := Load(node)
+		if !ok {
+			unknown = append(unknown, node)
+			continue
+		}
+		if s.Match(f) {
+			matched = append(matched, node)
+		}
+	}
+	return matched, unknown
+}
+
+// match 判断单个条件，ip 字段任一地址满足即可
+func (c condition) match(f types.Facts) bool {
+	switch c.key {
+	case "ip":
+		if c.op == "!=" {
+			for _, ip := range f.IPs {
+				if !compare(ip, "!=", c.value) {
+					return false
+				}
+			}
+			return true
+		}
+		for _, ip := range f.IPs {
+			if compare(ip, c.op, c.value) {
+				return true
+			}
+		}
+		return false
+	case "cpus":
+		return compare(strconv.Itoa(f.CPUs), c.op, c.value)
+	case "memory":
+		return compare(strconv.FormatInt(f.MemoryMB, 10), c.op, c.value)
+	case "uptime":
+		return compare(strconv.FormatInt(f.Uptime, 10), c.op, c.value)
+	}
+	return compare(stringField(f, c.key), c.op, c.value)
+}
+
+// stringField 返回字符串字段的值
+func stringField(f types.Facts, key string) string {
+	switch key {
+	case "hostname":
+		return f.Hostname
+	case "os":
+		return f.OS
+	case "os_id":
+		return f.OSID
+	case "os_version":
+		return f.OSVersion
+	case "kernel":
+		return f.Kernel
+	case "arch":
+		return f.Arch
+	case "virt":
+		return f.Virtualization
+	}
+	return ""
+}
+
+// compare 按运算符比较实际值和条件值
+func compare(actual, op, expected string) bool {
+	switch op {
+	case "=", "!=":
+		a, e := strings.ToLower(actual), strings.ToLower(expected)
+		matched, err := path.Match(e, a)
+		if err != nil {
+			matched = a == e
+		}
+		return matched == (op == "=")
+	case "~":
+		return strings.Contains(strings.ToLower(actual), strings.ToLower(expected))
+	}
+
+	cmp := compareVersion(actual, expected)
+	switch op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+// compareVersion 按各段数值比较两个数字或版本号，如 5.15.0-91 与 5.4，非数字部分按字符串比较
+func compareVersion(a, b string) int {
+	as, bs := versionParts(a), versionParts(b)
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xn, xerr := strconv.ParseInt(x, 10, 64)
+		yn, yerr := strconv.ParseInt(y, 10, 64)
+		switch {
+		case xerr == nil && yerr == nil:
+			if xn != yn {
+				if xn < yn {
+					return -1
+				}
+				return 1
+			}
+		case x != y:
+			return strings.Compare(x, y)
+		}
+	}
+	return 0
+}
+
+// versionParts 将版本号按非字母数字字符拆分
+func versionParts(v string) []string {
+	return strings.FieldsFunc(v, func(r rune) bool {
+		return !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+}
+
+// parseMB 解析带单位的容量，如 512M、16G、1T，不带单位时按 MB
+func parseMB(s string) (int64, error) {
+	units := map[byte]float64{'M': 1, 'G': 1024, 'T': 1024 * 1024}
+	value := strings.ToUpper(strings.TrimSuffix(strings.TrimSpace(s), "B"))
+	scale := 1.0
+	if value != "" {
+		if u, ok := units[value[len(value)-1]]; ok {
+			scale = u
+			value = value[:len(value)-1]
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("无效的容量: %s", s)
+	}
+	return int64(n * scale), nil
+}
@@ -0,0 +1,118 @@
+package fanout
+
+import (
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// 默认限制：同时最多处理的节点数和每秒新建的SSH连接数
+// 过高的并发会触发 sshd 的 MaxStartups 限制并耗尽本地文件描述符
+const (
+	DefaultWorkers  = 64
+	DefaultDialRate = 50
+)
+
+// Limiter 限制并发处理的节点数和新建连接的速率
+// 同一个 Limiter 应被一次操作中的所有并发路径共享；nil 表示不限制
+type Limiter struct {
+	workers      int
+	dialInterval time.Duration
+
+	mu       sync.Mutex
+	nextDial time.Time
+}
+
+// New 创建限制器，workers 或 dialRate 小于等于 0 时该项不限制
+func New(workers int, dialRate float64) *Limiter {
+	l := &Limiter{workers: workers}
+	if dialRate > 0 {
+		l.dialInterval = time.Duration(float64(time.Second) / dialRate)
+	}
+	return l
+}
+
+// Default 创建使用默认限制的限制器
+func Default() *Limiter {
+	return New(DefaultWorkers, DefaultDialRate)
+}
+
+// ForGroup 按 命令行参数 > 组配置 > 默认值 的优先级创建限制器
+// 参数为 0 表示未指定
+func ForGroup(group model.Group, workers int, dialRate float64) *Limiter {
+	if workers <= 0 {
+		workers = group.Fanout
+	}
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	if dialRate <= 0 {
+		dialRate = group.DialRate
+	}
+	if dialRate <= 0 {
+		dialRate = DefaultDialRate
+	}
+	return New(workers, dialRate)
+}
+
+// Workers 返回并发上限，0 表示不限制
+func (l *Limiter) Workers() int {
+	if l == nil {
+		return 0
+	}
+	return l.workers
+}
+
+// DialRate 返回每秒新建连接数上限，0 表示不限制
+func (l *Limiter) DialRate() float64 {
+	if l == nil || l.dialInterval == 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(l.dialInterval)
+}
+
+// Run 以受限的并发度执行 n 个任务，所有任务完成后返回
+func (l *Limiter) Run(n int, fn func(i int)) {
+	workers := l.Workers()
+	if workers <= 0 || workers > n {
+		workers = n
+	}
+
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				fn(i)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+}
+
+// WaitDial 阻塞直到允许发起下一个新连接
+func (l *Limiter) WaitDial() {
+	if l == nil || l.dialInterval == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.nextDial.Before(now) {
+		l.nextDial = now
+	}
+	wait := l.nextDial.Sub(now)
+	l.nextDial = l.nextDial.Add(l.dialInterval)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
@@ -0,0 +1,228 @@
+package health
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// 检查结果的保留时长，每次检查后清理更早的记录
+const historyRetention = 30 * 24 * time.Hour
+
+// 检查 shell 时执行的命令和期望的输出
+const shellProbe = "echo cm-check"
+
+// 窗口内状态变化不少于该次数的节点视为抖动
+const flapThreshold = 3
+
+// CheckNode 依次检查节点的 TCP 可达、SSH 认证和 shell 启动，在第一个失败的阶段停止
+func CheckNode(node model.Node, timeout time.Duration) model.NodeCheck {
+	check := model.NodeCheck{
+		ID:        fmt.Sprintf("%s-%s", time.Now().Format("20060102150405.000"), ip_util.GenerateShortID()),
+		NodeID:    node.ID,
+		NodeIP:    node.IP,
+		GroupName: node.Group,
+		CheckedAt: time.Now(),
+	}
+
+	// 经跳板机（节点或所在组配置）的节点无法从本机直接探测端口，以经跳板链建立 SSH 连接的结果为准
+	chain, err := sshconn.JumpChain(node)
+	if err != nil {
+		check.Error = fmt.Sprintf("解析跳板机失败: %v", err)
+		return check
+	}
+	if len(chain) == 0 {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", sshconn.Addr(node), timeout)
+		if err != nil {
+			check.Error = fmt.Sprintf("TCP 连接失败: %v", err)
+			return check
+		}
+		conn.Close()
+		check.TCPLatency = time.Since(start).Milliseconds()
+		check.Reachable = true
+	}
+
+	// 使用独立的连接，每次检查都重新认证，也不影响共享连接池中其他任务正在使用的连接
+	start := time.Now()
+	client, err := sshconn.Dial(node, timeout)
+	if err != nil {
+		check.Error = fmt.Sprintf("SSH 连接失败: %v", err)
+		return check
+	}
+	defer client.Close()
+	check.SSHLatency = time.Since(start).Milliseconds()
+	check.Reachable = true
+	check.AuthOK = true
+
+	start = time.Now()
+	session, err := client.NewSession()
+	if err != nil {
+		check.Error = fmt.Sprintf("创建会话失败: %v", err)
+		return check
+	}
+	defer session.Close()
+
+	// 命令没有按时结束时返回，延迟关闭的连接会终止会话
+	var output []byte
+	done := make(chan struct{})
+	go func() {
+		output, err = session.CombinedOutput(shellProbe)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		check.Error = "启动 shell 超时"
+		return check
+	}
+	if err != nil {
+		check.Error = fmt.Sprintf("启动 shell 失败: %v %s", err, strings.TrimSpace(string(output)))
+		return check
+	}
+	if strings.TrimSpace(string(output)) != "cm-check" {
+		check.Error = fmt.Sprintf("shell 输出异常: %q", strings.TrimSpace(string(output)))
+		return check
+	}
+	check.ShellLatency = time.Since(start).Milliseconds()
+	check.ShellOK = true
+	check.Usable = true
+	return check
+}
+
+// CheckNodes 并发检查节点并保存结果，返回与 nodes 顺序一致的检查结果
+func CheckNodes(nodes []model.Node, limiter *fanout.Limiter, timeout time.Duration) ([]model.NodeCheck, error) {
+	checks := make([]model.NodeCheck, len(nodes))
+	errs := make([]error, len(nodes))
+	limiter.Run(len(nodes), func(i int) {
+		limiter.WaitDial()
+		checks[i] = CheckNode(nodes[i], timeout)
+		errs[i] = crud.SaveNodeCheck(checks[i])
+	})
+
+	for _, err := range errs {
+		if err != nil {
+			return checks, fmt.Errorf("保存检查结果失败: %v", err)
+		}
+	}
+	if _, err := crud.PruneNodeChecks(time.Now().Add(-historyRetention)); err != nil {
+		return checks, fmt.Errorf("清理检查历史失败: %v", err)
+	}
+	return checks, nil
+}
+
+// CheckGroup 检查组内所有节点
+func CheckGroup(group model.Group, fanoutLimit int, dialRate float64, timeout time.Duration) ([]model.Node, []model.NodeCheck, error) {
+	nodes, err := crud.GetNodesInGroup(group.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	checks, err := CheckNodes(nodes, fanout.ForGroup(group, fanoutLimit, dialRate), timeout)
+	return nodes, checks, err
+}
+
+// NodeStats 是节点在统计窗口内的检查统计
+type NodeStats struct {
+	NodeID  string
+	IP      string
+	Checks  int
+	Up      int
+	Changes int // 可用状态变化的次数
+	Last    model.NodeCheck
+}
+
+// Availability 返回可用次数占检查次数的百分比
+func (s NodeStats) Availability() float64 {
+	if s.Checks == 0 {
+		return 0
+	}
+	return float64(s.Up) * 100 / float64(s.Checks)
+}
+
+// Flapping 返回节点在窗口内是否频繁在可用和不可用之间切换
+func (s NodeStats) Flapping() bool {
+	return s.Changes >= flapThreshold
+}
+
+// GroupStats 统计组内节点在 since 之后的检查结果，返回以节点ID为键的统计和整个组的可用率
+// 组的可用率为所有检查中可用的比例，没有检查记录时为 -1
+func GroupStats(groupName string, since time.Time) (map[string]NodeStats, float64, error) {
+	checks, err := crud.ListNodeChecks(groupName, since)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stats := make(map[string]NodeStats)
+	total, up := 0, 0
+	for _, check := range checks {
+		s, ok := stats[check.NodeID]
+		if ok && s.Last.Usable != check.Usable {
+			s.Changes++
+		}
+		s.NodeID, s.IP, s.Last = check.NodeID, check.NodeIP, check
+		s.Checks++
+		total++
+		if check.Usable {
+			s.Up++
+			up++
+		}
+		stats[check.NodeID] = s
+	}
+
+	if total == 0 {
+		return stats, -1, nil
+	}
+	return stats, float64(up) * 100 / float64(total), nil
+}
+
+// FlappingNodes 返回抖动节点的IP，按IP排序
+func FlappingNodes(stats map[string]NodeStats) []string {
+	var ips []string
+	for _, s := range stats {
+		if s.Flapping() {
+			ips = append(ips, s.IP)
+		}
+	}
+	sort.Strings(ips)
+	return ips
+}
+
+// GroupResult 是一个组的检查结果
+type GroupResult struct {
+	Group  model.Group
+	Nodes  []model.Node
+	Checks []model.NodeCheck
+	Err    error
+}
+
+// CheckAll 依次检查每个组，names 为空时检查所有组
+func CheckAll(names []string, fanoutLimit int, dialRate float64, timeout time.Duration) ([]GroupResult, error) {
+	var groups []model.Group
+	if len(names) == 0 {
+		var err error
+		if groups, err = crud.ListGroups(); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range names {
+		group, err := crud.GetGroup(name)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+
+	results := make([]GroupResult, 0, len(groups))
+	for _, group := range groups {
+		nodes, checks, err := CheckGroup(group, fanoutLimit, dialRate, timeout)
+		results = append(results, GroupResult{Group: group, Nodes: nodes, Checks: checks, Err: err})
+	}
+	return results, nil
+}
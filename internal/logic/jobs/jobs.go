@@ -0,0 +1,343 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
+	"zhaowanpeng/cluster-manager/internal/types"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// 节点上作业的状态
+const (
+	StateRunning = "running"
+	StateExited  = "exit"    // 后跟退出码，如 "exit 0"
+	StateKilled  = "killed"  // 被 jobs kill 终止
+	StateLost    = "lost"    // 进程已不存在且没有退出码，如节点重启过
+	StateMissing = "missing" // 节点上没有作业目录
+)
+
+// remoteDir 返回作业在节点上的目录，位于登录用户的主目录下
+// 目录按节点IP区分，避免主目录共享（如NFS）的节点互相覆盖；作业ID只包含十六进制字符，无需转义
+func remoteDir(jobID, ip string) string {
+	return `"$HOME/.cluster-manager/jobs/` + jobID + "/" + ip + `"`
+}
+
+// startScript 返回在节点上后台启动命令的脚本，输出启动的进程号
+// 命令在新会话中由 nohup 运行，脱离SSH连接；进程号、输出和退出码写入作业目录
+func startScript(jobID, ip, command string) string {
+	wrapper := `echo $$ > "$0/pid"; sh -c "$1"; echo $? > "$0/exit.tmp"; mv -f "$0/exit.tmp" "$0/exit"`
+	return fmt.Sprintf(`d=%s; mkdir -p "$d" && cd && (setsid nohup sh -c %s "$d" %s > "$d/output" 2>&1 < /dev/null &) && `+
+		`i=0; while [ ! -s "$d/pid" ] && [ $i -lt 50 ]; do sleep 0.1; i=$((i+1)); done; cat "$d/pid"`,
+		remoteDir(jobID, ip), utils.ShellQuote(wrapper), utils.ShellQuote(command))
+}
+
+// statusScript 返回查询节点上作业状态的脚本，输出为上面定义的状态之一
+func statusScript(jobID, ip string) string {
+	return fmt.Sprintf(`d=%s; if [ -f "$d/exit" ]; then echo "%s $(cat "$d/exit")"; `+
+		`elif [ -f "$d/killed" ]; then echo %s; `+
+		`elif [ -s "$d/pid" ] && kill -0 "$(cat "$d/pid")" 2>/dev/null; then echo %s; `+
+		`elif [ -d "$d" ]; then echo %s; else echo %s; fi`,
+		remoteDir(jobID, ip), StateExited, StateKilled, StateRunning, StateLost, StateMissing)
+}
+
+// killScript 返回终止节点上作业进程组的脚本，信号发送成功后留下终止标记，查询时据此区分被终止和丢失的作业
+func killScript(jobID, ip, signal string) string {
+	return fmt.Sprintf(`d=%s; if [ -f "$d/exit" ]; then echo finished; `+
+		`elif [ -s "$d/pid" ] && kill -0 -- "-$(cat "$d/pid")" 2>/dev/null; then `+
+		`kill -s %s -- "-$(cat "$d/pid")" && touch "$d/killed" && echo killed; `+
+		`else echo "not running"; fi`,
+		remoteDir(jobID, ip), signal)
+}
+
+// pruneScript 返回删除节点上作业目录的脚本，作业仍在运行时不删除
+// 作业目录下没有其他节点的目录时一并删除
+func pruneScript(jobID, ip string) string {
+	return fmt.Sprintf(`d=%s; if [ ! -f "$d/exit" ] && [ -s "$d/pid" ] && kill -0 -- "-$(cat "$d/pid")" 2>/dev/null; then echo running; `+
+		`else rm -rf "$d" && { rmdir "$(dirname "$d")" 2>/dev/null; echo removed; }; fi`,
+		remoteDir(jobID, ip))
+}
+
+// Start 在节点上后台启动命令并保存作业，返回作业和每个节点的启动结果
+// 启动失败的节点同样记录在作业中，查询时显示为未启动
+func Start(groupName string, nodes []model.Node, command string, limiter *fanout.Limiter, timeout time.Duration) (model.Job, []types.Result, error) {
+	job := model.Job{
+		ID:        ip_util.GenerateShortID(),
+		GroupName: groupName,
+		Command:   command,
+		StartTime: time.Now(),
+	}
+
+	jobNodes := make([]model.JobNode, len(nodes))
+	results := make([]types.Result, len(nodes))
+	limiter.Run(len(nodes), func(i int) {
+		node := nodes[i]
+		jobNode := model.JobNode{
+			ID:        fmt.Sprintf("%s-%s", job.ID, node.IP),
+			JobID:     job.ID,
+			NodeID:    node.ID,
+			NodeIP:    node.IP,
+			Port:      node.Port,
+			User:      node.User,
+			CheckedAt: time.Now(),
+		}
+		result := types.Result{IP: node.IP}
+
+		if !sshconn.Shared.Connected(node) {
+			limiter.WaitDial()
+		}
+		stdout, stderr, err := sshconn.Shared.Exec(node, startScript(job.ID, node.IP, command), timeout)
+		pid, convErr := strconv.Atoi(strings.TrimSpace(stdout))
+		switch {
+		case err != nil:
+			result.Msg = fmt.Sprintf("启动失败: %v %s", err, strings.TrimSpace(stderr))
+		case convErr != nil:
+			result.Msg = fmt.Sprintf("启动失败: 没有获取到进程号 %s", strings.TrimSpace(stderr))
+		default:
+			jobNode.PID = pid
+			jobNode.State = StateRunning
+			result.Success = true
+			result.Msg = fmt.Sprintf("进程号 %d", pid)
+		}
+		if !result.Success {
+			jobNode.State = result.Msg
+		}
+
+		jobNodes[i] = jobNode
+		results[i] = result
+	})
+
+	if err := crud.CreateJob(job, jobNodes); err != nil {
+		return job, results, fmt.Errorf("保存作业失败: %v", err)
+	}
+	return job, results, nil
+}
+
+// Load 读取作业及其节点，返回用于连接的节点（按作业中的顺序）和以IP为键的节点记录
+// 节点已保存时使用保存的连接信息，否则按作业中记录的地址和用户连接
+func Load(jobID string) (model.Job, []model.Node, map[string]model.JobNode, error) {
+	job, err := crud.GetJob(jobID)
+	if err != nil {
+		return job, nil, nil, err
+	}
+	jobNodes, err := crud.GetJobNodes(jobID)
+	if err != nil {
+		return job, nil, nil, err
+	}
+
+	nodes := make([]model.Node, 0, len(jobNodes))
+	byIP := make(map[string]model.JobNode, len(jobNodes))
+	for _, jn := range jobNodes {
+		node, err := crud.GetNode(jn.NodeID)
+		if err != nil {
+			node = model.Node{ID: jn.NodeID, IP: jn.NodeIP, Port: jn.Port, User: jn.User, Group: job.GroupName}
+		}
+		nodes = append(nodes, node)
+		byIP[jn.NodeIP] = jn
+	}
+	return job, nodes, byIP, nil
+}
+
+// Limiter 返回查询作业时使用的并发限制，作业所在的组已删除时使用默认限制
+func Limiter(job model.Job, fanoutLimit int, dialRate float64) *fanout.Limiter {
+	group, err := crud.GetGroup(job.GroupName)
+	if err != nil {
+		return fanout.New(fanoutLimit, dialRate)
+	}
+	return fanout.ForGroup(group, fanoutLimit, dialRate)
+}
+
+// Status 查询作业在各节点上的状态并保存，结果的输出为状态描述，便于合并显示
+func Status(job model.Job, nodes []model.Node, jobNodes map[string]model.JobNode, limiter *fanout.Limiter, timeout time.Duration) map[string]session.ExecResult {
+	return poll(nodes, jobNodes, limiter, func(node model.Node, jn model.JobNode) session.ExecResult {
+		stdout, stderr, err := sshconn.Shared.Exec(node, statusScript(job.ID, node.IP), timeout)
+		if err != nil {
+			return session.NewExecResult(node, "", queryError(err, stderr))
+		}
+
+		state := strings.TrimSpace(stdout)
+		crud.UpdateJobNodeState(jn.ID, state, time.Now())
+		return stateResult(node, state)
+	})
+}
+
+// Output 读取作业在各节点上的输出，tail 大于 0 时只读取最后 tail 行
+// 结果的退出状态按节点上作业的状态填充，仍在运行的节点视为成功
+func Output(job model.Job, nodes []model.Node, jobNodes map[string]model.JobNode, tail int, limiter *fanout.Limiter, timeout time.Duration) map[string]session.ExecResult {
+	read := `cat "$d/output"`
+	if tail > 0 {
+		read = fmt.Sprintf(`tail -n %d "$d/output"`, tail)
+	}
+
+	return poll(nodes, jobNodes, limiter, func(node model.Node, jn model.JobNode) session.ExecResult {
+		script := fmt.Sprintf(`d=%s; [ -f "$d/output" ] && %s; echo; %s`, remoteDir(job.ID, node.IP), read, statusScript(job.ID, node.IP))
+		stdout, stderr, err := sshconn.Shared.Exec(node, script, timeout)
+		if err != nil {
+			return session.NewExecResult(node, "", queryError(err, stderr))
+		}
+
+		// 最后一行是作业状态
+		stdout = strings.TrimRight(stdout, "\n")
+		output, state := "", stdout
+		if i := strings.LastIndex(stdout, "\n"); i >= 0 {
+			output, state = strings.TrimRight(stdout[:i], "\n"), stdout[i+1:]
+		}
+		crud.UpdateJobNodeState(jn.ID, state, time.Now())
+
+		result := stateResult(node, state)
+		if state == StateRunning || strings.HasPrefix(state, StateExited+" ") {
+			result.Output = output
+		} else if output != "" {
+			result.Output = output + "\n" + result.Output
+		}
+		return result
+	})
+}
+
+// Kill 向作业在各节点上的进程组发送信号，结果的输出为操作结果
+func Kill(job model.Job, nodes []model.Node, jobNodes map[string]model.JobNode, signal string, limiter *fanout.Limiter, timeout time.Duration) map[string]session.ExecResult {
+	return poll(nodes, jobNodes, limiter, func(node model.Node, jn model.JobNode) session.ExecResult {
+		stdout, stderr, err := sshconn.Shared.Exec(node, killScript(job.ID, node.IP, signal), timeout)
+		if err != nil {
+			return session.NewExecResult(node, "", fmt.Errorf("发送信号失败: %v %s", err, strings.TrimSpace(stderr)))
+		}
+
+		switch strings.TrimSpace(stdout) {
+		case "killed":
+			crud.UpdateJobNodeState(jn.ID, StateKilled, time.Now())
+			return session.NewExecResult(node, "已发送 SIG"+signal, nil)
+		case "finished":
+			return session.NewExecResult(node, "作业已结束", nil)
+		default:
+			return session.NewExecResult(node, "", errors.New("作业没有在运行"))
+		}
+	})
+}
+
+// Prune 删除作业在各节点上的目录（输出、进程号和退出码），仍在运行的节点保留，返回是否所有已启动的节点都已删除
+// 全部删除后作业记录也一并删除
+func Prune(job model.Job, nodes []model.Node, jobNodes map[string]model.JobNode, limiter *fanout.Limiter, timeout time.Duration) (map[string]session.ExecResult, bool, error) {
+	results := poll(nodes, jobNodes, limiter, func(node model.Node, jn model.JobNode) session.ExecResult {
+		stdout, stderr, err := sshconn.Shared.Exec(node, pruneScript(job.ID, node.IP), timeout)
+		if err != nil {
+			return session.NewExecResult(node, "", fmt.Errorf("删除作业目录失败: %v %s", err, strings.TrimSpace(stderr)))
+		}
+		if strings.TrimSpace(stdout) == "running" {
+			return session.NewExecResult(node, "", errors.New("作业仍在运行，未删除，可先使用 jobs kill 终止"))
+		}
+		return session.NewExecResult(node, "已删除作业目录", nil)
+	})
+
+	for _, node := range nodes {
+		if jobNodes[node.IP].PID != 0 && !results[node.IP].Success {
+			return results, false, nil
+		}
+	}
+	if err := crud.DeleteJob(job.ID); err != nil {
+		return results, true, fmt.Errorf("删除作业记录失败: %v", err)
+	}
+	return results, true, nil
+}
+
+// poll 并发在已启动作业的节点上执行查询，未能启动的节点直接返回启动时的错误
+func poll(nodes []model.Node, jobNodes map[string]model.JobNode, limiter *fanout.Limiter, query func(model.Node, model.JobNode) session.ExecResult) map[string]session.ExecResult {
+	results := make(map[string]session.ExecResult, len(nodes))
+	var mutex sync.Mutex
+
+	limiter.Run(len(nodes), func(i int) {
+		node := nodes[i]
+		jn := jobNodes[node.IP]
+
+		var result session.ExecResult
+		if jn.PID == 0 {
+			result = session.NewExecResult(node, "", fmt.Errorf("作业未在该节点上启动: %s", jn.State))
+		} else {
+			if !sshconn.Shared.Connected(node) {
+				limiter.WaitDial()
+			}
+			result = query(node, jn)
+		}
+
+		mutex.Lock()
+		results[node.IP] = result
+		mutex.Unlock()
+	})
+	return results
+}
+
+// stateResult 将节点上的作业状态转换为执行结果，已结束的作业按退出码填充退出状态
+func stateResult(node model.Node, state string) session.ExecResult {
+	switch {
+	case state == StateRunning:
+		return session.NewExecResult(node, "运行中", nil)
+	case strings.HasPrefix(state, StateExited+" "):
+		code, err := strconv.Atoi(strings.TrimPrefix(state, StateExited+" "))
+		if err != nil {
+			return session.NewExecResult(node, "", fmt.Errorf("无法解析退出码: %q", state))
+		}
+		if code != 0 {
+			return session.NewExecResult(node, "", session.ExitCodeError(code))
+		}
+		return session.NewExecResult(node, "已结束", nil)
+	case state == StateKilled:
+		return session.NewExecResult(node, "", errors.New("已被 jobs kill 终止"))
+	case state == StateLost:
+		return session.NewExecResult(node, "", errors.New("进程已不存在且没有退出码，节点可能重启过"))
+	case state == StateMissing:
+		return session.NewExecResult(node, "", errors.New("节点上没有该作业的目录"))
+	}
+	return session.NewExecResult(node, "", fmt.Errorf("未知状态: %q", state))
+}
+
+// queryError 合并查询失败时的错误和标准错误
+func queryError(err error, stderr string) error {
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Errorf("查询失败: %v: %s", err, stderr)
+	}
+	return fmt.Errorf("查询失败: %v", err)
+}
+
+// Summary 按节点最近一次查询到的状态统计作业，用于列表显示
+func Summary(jobNodes []model.JobNode) string {
+	counts := make(map[string]int)
+	var order []string
+	for _, jn := range jobNodes {
+		label := stateLabel(jn)
+		if counts[label] == 0 {
+			order = append(order, label)
+		}
+		counts[label]++
+	}
+
+	parts := make([]string, 0, len(order))
+	for _, label := range order {
+		parts = append(parts, fmt.Sprintf("%s %d", label, counts[label]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// stateLabel 返回节点状态的简短描述
+func stateLabel(jn model.JobNode) string {
+	switch {
+	case jn.PID == 0:
+		return "未启动"
+	case jn.State == StateRunning:
+		return "运行中"
+	case jn.State == StateExited+" 0":
+		return "成功"
+	case strings.HasPrefix(jn.State, StateExited+" "):
+		return "失败"
+	case jn.State == StateKilled:
+		return "已终止"
+	}
+	return "未知"
+}
@@ -0,0 +1,54 @@
+package keys
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
+	"zhaowanpeng/cluster-manager/internal/types"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// AuthorizeCommand 生成幂等追加公钥到 ~/.ssh/authorized_keys 的命令
+// 公钥已存在时输出 present，新追加时输出 added
+func AuthorizeCommand(authorizedKey string) string {
+	key := utils.ShellQuote(authorizedKey)
+	return "umask 077; mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys && chmod 700 ~/.ssh && chmod 600 ~/.ssh/authorized_keys && " +
+		fmt.Sprintf("if grep -qxF %s ~/.ssh/authorized_keys; then echo present; else echo %s >> ~/.ssh/authorized_keys && echo added; fi", key, key)
+}
+
+// MigrateNodes 验证节点能否使用私钥登录，验证通过后切换为密钥认证并清除密码
+// 验证通过共享连接池并按节点配置的跳板机连接，连接池按认证信息区分连接，不会复用以密码认证的连接
+func MigrateNodes(nodes []model.Node, keyPath string, timeout time.Duration, limiter *fanout.Limiter) ([]types.Result, error) {
+	if _, err := utils.LoadSigner(keyPath); err != nil {
+		return nil, err
+	}
+
+	resultChan := make(chan types.Result, len(nodes))
+	limiter.Run(len(nodes), func(i int) {
+		node := nodes[i]
+		limiter.WaitDial()
+
+		candidate := node
+		candidate.AuthType, candidate.KeyPath, candidate.Password = model.AuthKey, keyPath, ""
+		if ok, status := sshconn.Check(candidate, timeout); !ok {
+			resultChan <- types.Result{IP: node.IP, Msg: "key login failed: " + status}
+			return
+		}
+
+		if err := crud.SwitchNodeToKey(node.ID, keyPath); err != nil {
+			resultChan <- types.Result{IP: node.IP, Msg: fmt.Sprintf("Database error: %v", err)}
+			return
+		}
+		resultChan <- types.Result{IP: node.IP, Msg: "migrated to key", Success: true}
+	})
+	close(resultChan)
+
+	results := make([]types.Result, 0, len(nodes))
+	for result := range resultChan {
+		results = append(results, result)
+	}
+	return results, nil
+}
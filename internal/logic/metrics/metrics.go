@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+)
+
+// 指标名前缀
+const namespace = "cluster_manager"
+
+// family 是同名的一组样本，输出时带 HELP 和 TYPE 说明
+type family struct {
+	name    string
+	help    string
+	typ     string
+	samples []sample
+}
+
+// sample 是一个样本，labels 为按顺序排列的标签名和值
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// add 添加样本，labels 依次为标签名和标签值
+func (f *family) add(value float64, labels ...string) {
+	f.samples = append(f.samples, sample{labels: labels, value: value})
+}
+
+// newFamily 创建指标
+func newFamily(name, typ, help string) *family {
+	return &family{name: namespace + "_" + name, typ: typ, help: help}
+}
+
+// Write 从节点检查结果和命令记录中读取数据，以 Prometheus 文本格式输出指标
+// 节点指标带 group 和 node（节点IP）标签，定时任务指标带 schedule 和 group 标签
+func Write(w io.Writer) error {
+	nodeFamilies, err := nodeMetrics()
+	if err != nil {
+		return err
+	}
+	scheduleFamilies, err := scheduleMetrics()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, f := range append(nodeFamilies, scheduleFamilies...) {
+		if len(f.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buf, "# HELP %s %s\n", f.name, f.help)
+		fmt.Fprintf(&buf, "# TYPE %s %s\n", f.name, f.typ)
+		for _, s := range f.samples {
+			buf.WriteString(f.name)
+			if len(s.labels) > 0 {
+				buf.WriteByte('{')
+				for i := 0; i+1 < len(s.labels); i += 2 {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					fmt.Fprintf(&buf, "%s=\"%s\"", s.labels[i], escapeLabel(s.labels[i+1]))
+				}
+				buf.WriteByte('}')
+			}
+			fmt.Fprintf(&buf, " %s\n", strconv.FormatFloat(s.value, 'f', -1, 64))
+		}
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// nodeMetrics 返回节点的可用性、SSH 握手耗时、检查时间和会话记录中保留的命令执行次数
+func nodeMetrics() ([]*family, error) {
+	nodes, err := crud.ListNodes()
+	if err != nil {
+		return nil, fmt.Errorf("获取节点失败: %v", err)
+	}
+	latest, err := crud.LatestNodeChecks(false)
+	if err != nil {
+		return nil, fmt.Errorf("获取检查结果失败: %v", err)
+	}
+	lastUsable, err := crud.LatestNodeChecks(true)
+	if err != nil {
+		return nil, fmt.Errorf("获取检查结果失败: %v", err)
+	}
+	counts, err := crud.CountNodeCommands()
+	if err != nil {
+		return nil, fmt.Errorf("统计命令记录失败: %v", err)
+	}
+
+	up := newFamily("node_up", "gauge", "Whether the last health check passed TCP, SSH authentication and shell stages (1) or not (0).")
+	reachable := newFamily("node_reachable", "gauge", "Whether the node was reachable over TCP in the last health check.")
+	auth := newFamily("node_ssh_auth_ok", "gauge", "Whether SSH authentication succeeded in the last health check.")
+	handshake := newFamily("node_ssh_handshake_seconds", "gauge", "SSH handshake and authentication latency in the last health check.")
+	lastCheck := newFamily("node_last_check_timestamp_seconds", "gauge", "Unix time of the last health check.")
+	lastSuccess := newFamily("node_last_success_timestamp_seconds", "gauge", "Unix time of the last health check in which the node was usable.")
+	commands := newFamily("node_commands", "gauge", "Command executions on the node by result in the retained session history; decreases when history is pruned.")
+
+	for _, node := range nodes {
+		if check, ok := latest[node.ID]; ok {
+			up.add(boolValue(check.Usable), "group", node.Group, "node", node.IP)
+			reachable.add(boolValue(check.Reachable), "group", node.Group, "node", node.IP)
+			auth.add(boolValue(check.AuthOK), "group", node.Group, "node", node.IP)
+			if check.AuthOK {
+				handshake.add(float64(check.SSHLatency)/1000, "group", node.Group, "node", node.IP)
+			}
+			lastCheck.add(unixTime(check.CheckedAt), "group", node.Group, "node", node.IP)
+		}
+		if check, ok := lastUsable[node.ID]; ok {
+			lastSuccess.add(unixTime(check.CheckedAt), "group", node.Group, "node", node.IP)
+		}
+	}
+	for _, c := range counts {
+		commands.add(float64(c.Success), "group", c.GroupName, "node", c.NodeIP, "result", "success")
+		commands.add(float64(c.Failure), "group", c.GroupName, "node", c.NodeIP, "result", "failure")
+	}
+
+	return []*family{up, reachable, auth, handshake, lastCheck, lastSuccess, commands}, nil
+}
+
+// scheduleMetrics 返回定时任务最近一次执行的结果
+func scheduleMetrics() ([]*family, error) {
+	schedules, err := crud.ListSchedules()
+	if err != nil {
+		return nil, fmt.Errorf("获取定时任务失败: %v", err)
+	}
+
+	lastRun := newFamily("schedule_last_run_timestamp_seconds", "gauge", "Unix time of the last run of the schedule.")
+	lastChanged := newFamily("schedule_last_changed_timestamp_seconds", "gauge", "Unix time of the last run whose merged result differed from the previous run.")
+	failed := newFamily("schedule_failed_nodes", "gauge", "Number of nodes on which a command failed in the last run.")
+	success := newFamily("schedule_node_success", "gauge", "Whether all commands of the last run succeeded on the node (1) or not (0).")
+
+	for _, s := range schedules {
+		if s.LastRunAt.IsZero() {
+			continue
+		}
+		lastRun.add(unixTime(s.LastRunAt), "schedule", s.Name, "group", s.GroupName)
+		if !s.LastChangedAt.IsZero() {
+			lastChanged.add(unixTime(s.LastChangedAt), "schedule", s.Name, "group", s.GroupName)
+		}
+		if s.LastSessionID == "" {
+			continue
+		}
+
+		counts, err := crud.CountSessionCommands(s.LastSessionID)
+		if err != nil {
+			return nil, fmt.Errorf("统计定时任务 %s 的结果失败: %v", s.Name, err)
+		}
+		// 会话已被清理时没有记录，不输出节点结果
+		if len(counts) == 0 {
+			continue
+		}
+		nodesFailed := 0
+		for _, c := range counts {
+			ok := c.Failure == 0
+			if !ok {
+				nodesFailed++
+			}
+			success.add(boolValue(ok), "schedule", s.Name, "group", s.GroupName, "node", c.NodeIP)
+		}
+		failed.add(float64(nodesFailed), "schedule", s.Name, "group", s.GroupName)
+	}
+
+	return []*family{lastRun, lastChanged, failed, success}, nil
+}
+
+// Handler 返回输出指标的 HTTP 处理函数
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if err := Write(&buf); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(buf.Bytes())
+	})
+}
+
+// Serve 在 listener 上提供 /metrics，直到 ctx 结束
+func Serve(ctx context.Context, listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// escapeLabel 转义标签值中的反斜杠、双引号和换行
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// boolValue 将布尔值转换为 1 或 0
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// unixTime 返回以秒为单位的 Unix 时间
+func unixTime(t time.Time) float64 {
+	return float64(t.UnixNano()) / 1e9
+}
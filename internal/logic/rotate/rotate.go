@@ -0,0 +1,140 @@
+package rotate
+
+import (
+	"fmt"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/logic/users"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
+	"zhaowanpeng/cluster-manager/internal/types"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// Plan 描述每个节点要设置的新密码，键为节点ID
+type Plan map[string]string
+
+// NewPlan 为节点生成新密码，perGroup 为 true 时同一组的节点共用一个密码
+func NewPlan(nodes []model.Node, length int, perGroup bool) (Plan, error) {
+	plan := make(Plan)
+	groupPasswords := make(map[string]string)
+
+	for _, node := range nodes {
+		if perGroup {
+			if password, ok := groupPasswords[node.Group]; ok {
+				plan[node.ID] = password
+				continue
+			}
+		}
+
+		password, err := utils.GeneratePassword(length)
+		if err != nil {
+			return nil, err
+		}
+		plan[node.ID] = password
+		groupPasswords[node.Group] = password
+	}
+
+	return plan, nil
+}
+
+// Apply 按计划轮换节点密码
+// 每个节点依次执行：通过现有会话修改密码 -> 用新密码重新登录验证 -> 更新数据库；
+// 任何一步失败都通过同一会话恢复旧密码，再以实际能登录的密码为准更新数据库，保证保存的密码与节点一致
+func Apply(sm *session.SessionManager, nodes []model.Node, plan Plan, timeout time.Duration) []types.Result {
+	resultChan := make(chan types.Result, len(nodes))
+
+	// 并发度和建连速率沿用会话管理器的限制，验证登录时的新连接同样受限
+	sm.Limiter().Run(len(nodes), func(i int) {
+		resultChan <- rotateNode(sm, nodes[i], plan[nodes[i].ID], timeout)
+	})
+	close(resultChan)
+
+	results := make([]types.Result, 0, len(nodes))
+	for result := range resultChan {
+		results = append(results, result)
+	}
+	return results
+}
+
+// rotateNode 轮换单个节点的密码
+func rotateNode(sm *session.SessionManager, node model.Node, newPassword string, timeout time.Duration) types.Result {
+	result := types.Result{IP: node.IP}
+
+	// 没有保存密码的节点无法回滚，直接跳过
+	if node.Password == "" {
+		result.Msg = "skipped: no stored password"
+		return result
+	}
+	if newPassword == "" {
+		result.Msg = "skipped: no new password planned"
+		return result
+	}
+
+	ns, err := sm.GetOrCreateSession(node)
+	if err != nil {
+		result.Msg = fmt.Sprintf("connect failed: %v", err)
+		return result
+	}
+
+	// 修改命令失败或超时时密码可能已经生效，同样进入恢复流程
+	var status string
+	if _, err := ns.ExecuteCommand(users.PasswordCommand(node.User, newPassword), timeout); err != nil {
+		status = fmt.Sprintf("chpasswd failed: %v", err)
+	} else if ok, msg := verifyPassword(sm, node, newPassword, timeout); !ok {
+		status = fmt.Sprintf("verify failed: %s", msg)
+	} else if err := crud.UpdateNodePassword(node.ID, newPassword); err != nil {
+		status = fmt.Sprintf("save failed: %v", err)
+	} else {
+		result.Msg = "rotated"
+		result.Success = true
+		return result
+	}
+
+	result.Msg = rollback(sm, ns, node, newPassword, status, timeout)
+	return result
+}
+
+// rollback 恢复旧密码，并确认节点上实际生效的密码
+// 旧密码可以登录时数据库无需修改；只有新密码可以登录时保存新密码
+func rollback(sm *session.SessionManager, ns *session.NodeSession, node model.Node, newPassword, status string, timeout time.Duration) string {
+	_, restoreErr := ns.ExecuteCommand(users.PasswordCommand(node.User, node.Password), timeout)
+
+	ok, oldMsg := verifyPassword(sm, node, node.Password, timeout)
+	if ok {
+		if restoreErr != nil {
+			return fmt.Sprintf("%s, old password still in effect", status)
+		}
+		return fmt.Sprintf("%s, rolled back", status)
+	}
+
+	if ok, _ := verifyPassword(sm, node, newPassword, timeout); ok {
+		if err := crud.UpdateNodePassword(node.ID, newPassword); err != nil {
+			return fmt.Sprintf("%s, ROLLBACK FAILED: new password in effect but not saved: %v", status, err)
+		}
+		return fmt.Sprintf("%s, rollback failed: new password in effect and saved", status)
+	}
+
+	return fmt.Sprintf("%s, ROLLBACK FAILED: neither old nor new password works (%s)", status, oldMsg)
+}
+
+// verifyPassword 使用给定密码建立新的连接验证登录，按节点配置的跳板机连接
+// 不使用连接池中已认证的连接，确保每次验证都重新认证
+func verifyPassword(sm *session.SessionManager, node model.Node, password string, timeout time.Duration) (bool, string) {
+	node.Password, node.AuthType, node.KeyPath = password, model.AuthPassword, ""
+
+	sm.Limiter().WaitDial()
+	client, err := sshconn.Dial(node, timeout)
+	if err != nil {
+		return false, err.Error()
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return false, fmt.Sprintf("Failed to create session: %s", err.Error())
+	}
+	session.Close()
+	return true, "ssh success"
+}
@@ -0,0 +1,188 @@
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/logic/health"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+)
+
+// 检查到期任务的间隔，定时任务的修改在下一轮生效
+const tickInterval = time.Second
+
+// Daemon 定期读取定时任务并执行到期的任务，同一任务上次执行未结束时跳过本次
+// 设置了检查间隔时同时定期检查所有节点的可用性
+type Daemon struct {
+	mu      sync.Mutex
+	next    map[string]time.Time // 任务名 -> 下次执行时间
+	specs   map[string]string    // 任务名 -> 计算下次执行时间所用的配置，配置变化时重新计算
+	running map[string]bool
+	wg      sync.WaitGroup
+
+	checkEvery   time.Duration // 节点检查间隔，0 表示不检查
+	checkTimeout time.Duration
+	nextCheck    time.Time
+	checking     bool
+}
+
+// NewDaemon 创建定时任务守护进程
+func NewDaemon() *Daemon {
+	return &Daemon{
+		next:    make(map[string]time.Time),
+		specs:   make(map[string]string),
+		running: make(map[string]bool),
+	}
+}
+
+// SetHealthCheck 设置节点检查的间隔和每个阶段的超时时间，间隔为 0 时不检查
+func (d *Daemon) SetHealthCheck(every, timeout time.Duration) {
+	d.checkEvery = every
+	d.checkTimeout = timeout
+}
+
+// Serve 运行定时任务直到 ctx 结束，返回前等待正在执行的任务完成
+func (d *Daemon) Serve(ctx context.Context) error {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	for {
+		d.tick(time.Now())
+
+		select {
+		case <-ctx.Done():
+			d.wg.Wait()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick 重新读取定时任务，启动已到期的任务
+func (d *Daemon) tick(now time.Time) {
+	schedules, err := crud.ListSchedules()
+	if err != nil {
+		logf("读取定时任务失败: %v", err)
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.checkEvery > 0 && !d.checking && !now.Before(d.nextCheck) {
+		d.nextCheck = now.Add(d.checkEvery)
+		d.checking = true
+		d.wg.Add(1)
+		go d.check()
+	}
+
+	seen := make(map[string]bool, len(schedules))
+	for _, s := range schedules {
+		seen[s.Name] = true
+
+		spec := Describe(s)
+		if d.specs[s.Name] != spec {
+			next, err := NextRun(s, now)
+			if err != nil {
+				logf("[%s] %v", s.Name, err)
+			}
+			d.specs[s.Name], d.next[s.Name] = spec, next
+		}
+
+		next := d.next[s.Name]
+		if next.IsZero() || now.Before(next) || d.running[s.Name] {
+			continue
+		}
+
+		// 按开始时间计算下次执行时间，执行耗时不影响执行间隔
+		s.LastRunAt = now
+		if d.next[s.Name], err = NextRun(s, now); err != nil {
+			logf("[%s] %v", s.Name, err)
+		}
+		d.running[s.Name] = true
+		d.wg.Add(1)
+		go d.run(s)
+	}
+
+	// 已删除的任务不再执行
+	for name := range d.specs {
+		if !seen[name] {
+			delete(d.specs, name)
+			delete(d.next, name)
+		}
+	}
+}
+
+// run 执行一次定时任务并输出日志
+func (d *Daemon) run(s model.Schedule) {
+	defer d.wg.Done()
+	defer func() {
+		d.mu.Lock()
+		delete(d.running, s.Name)
+		d.mu.Unlock()
+	}()
+
+	logf("[%s] 开始在组 %s 上执行", s.Name, s.GroupName)
+	result, err := Run(s)
+	if err != nil {
+		logf("[%s] 执行失败: %v", s.Name, err)
+		return
+	}
+
+	failed := 0
+	for _, step := range result.Steps {
+		for _, r := range step.Results {
+			if !r.Success {
+				failed++
+			}
+		}
+	}
+	status := "结果未变化"
+	if result.Changed {
+		status = "结果已变化"
+	}
+	logf("[%s] 执行完成，会话 %s，%d 个节点失败，%s", s.Name, result.SessionID, failed, status)
+	if result.NotifyErr != nil {
+		logf("[%s] 发送通知失败: %v", s.Name, result.NotifyErr)
+	}
+}
+
+// check 检查所有组的节点并输出不可用的节点
+func (d *Daemon) check() {
+	defer d.wg.Done()
+	defer func() {
+		d.mu.Lock()
+		d.checking = false
+		d.mu.Unlock()
+	}()
+
+	results, err := health.CheckAll(nil, 0, 0, d.checkTimeout)
+	if err != nil {
+		logf("[check] 检查节点失败: %v", err)
+		return
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			logf("[check] 组 %s: %v", result.Group.Name, result.Err)
+		}
+		var down []string
+		for _, check := range result.Checks {
+			if !check.Usable {
+				down = append(down, check.NodeIP)
+			}
+		}
+		if len(down) > 0 {
+			logf("[check] 组 %s: %d/%d 个节点不可用: %s", result.Group.Name, len(down), len(result.Checks), session.CompressIPList(down))
+		}
+	}
+}
+
+// logf 输出带时间的守护进程日志
+func logf(format string, args ...interface{}) {
+	fmt.Printf("%s %s\n", color.HiBlackString(time.Now().Format("2006-01-02 15:04:05")), fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,232 @@
+package schedule
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/config"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/session"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// 最短执行间隔，避免误配置的定时任务持续占用节点
+const minInterval = 10 * time.Second
+
+// 通知命令和 webhook 的超时时间
+const notifyTimeout = 30 * time.Second
+
+// Step 是定时任务中一条命令的执行结果
+type Step struct {
+	Command string
+	Nodes   []model.Node // 执行该命令的节点，前面的命令失败的节点不再执行后续命令
+	Results map[string]session.ExecResult
+}
+
+// RunResult 是定时任务一次执行的结果
+type RunResult struct {
+	SessionID string
+	RunAt     time.Time
+	Steps     []Step
+	Summary   string // 本次的合并结果
+	Previous  string // 上次的合并结果，首次执行时为空
+	Changed   bool   // 合并结果与上次不同，首次执行不算变化
+	NotifyErr error  // 发送通知失败的原因
+}
+
+// Commands 返回定时任务要依次执行的命令，忽略空行和 # 开头的注释
+func Commands(s model.Schedule) []string {
+	var commands []string
+	for _, line := range strings.Split(s.Commands, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			commands = append(commands, line)
+		}
+	}
+	return commands
+}
+
+// Validate 检查定时任务的配置
+func Validate(s model.Schedule) error {
+	if (s.Cron == "") == (s.Interval == 0) {
+		return fmt.Errorf("需要指定 cron 表达式或执行间隔之一")
+	}
+	if s.Cron != "" {
+		if _, err := ParseCron(s.Cron); err != nil {
+			return err
+		}
+	}
+	if s.Interval != 0 && time.Duration(s.Interval)*time.Second < minInterval {
+		return fmt.Errorf("执行间隔不能小于 %v", minInterval)
+	}
+	if len(Commands(s)) == 0 {
+		return fmt.Errorf("没有要执行的命令")
+	}
+	if s.Timeout <= 0 {
+		return fmt.Errorf("无效的超时时间: %d", s.Timeout)
+	}
+	return nil
+}
+
+// NextRun 返回定时任务在 now 之后的下次执行时间
+// 按间隔执行的任务从上次执行时间算起，从未执行或已错过时立即执行
+func NextRun(s model.Schedule, now time.Time) (time.Time, error) {
+	if s.Cron != "" {
+		cron, err := ParseCron(s.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+		next := cron.Next(now)
+		if next.IsZero() {
+			return next, fmt.Errorf("cron 表达式 %q 没有匹配的时间", s.Cron)
+		}
+		return next, nil
+	}
+
+	next := s.LastRunAt.Add(time.Duration(s.Interval) * time.Second)
+	if next.Before(now) {
+		return now, nil
+	}
+	return next, nil
+}
+
+// Describe 返回执行时间的简短描述
+func Describe(s model.Schedule) string {
+	if s.Cron != "" {
+		return "cron " + s.Cron
+	}
+	return fmt.Sprintf("every %v", time.Duration(s.Interval)*time.Second)
+}
+
+// Run 在组上执行一次定时任务，记录为会话并保存合并结果，结果与上次不同时发送通知
+// 命令在同一会话中依次执行，某条命令失败的节点不再执行后续命令
+func Run(s model.Schedule) (*RunResult, error) {
+	group, nodes, err := session.ResolveNodes(session.ExecOptions{GroupName: s.GroupName, ExcludeNodes: s.Exclude})
+	if err != nil {
+		return nil, err
+	}
+
+	var normalizer *session.Normalizer
+	if s.Normalize {
+		cfg, err := config.Load()
+		if err != nil {
+			return nil, err
+		}
+		if normalizer, err = session.NewNormalizer(cfg.Merge); err != nil {
+			return nil, err
+		}
+	}
+
+	sm := session.NewSessionManager()
+	sm.SetLimiter(fanout.ForGroup(group, 0, 0))
+	defer sm.CloseAll()
+
+	recorder := session.NewRecorder(s.Name, "定时任务 "+s.Name, session.CurrentUserName(), group.Name)
+	if err := recorder.Start(); err != nil {
+		return nil, fmt.Errorf("记录会话失败: %v", err)
+	}
+	defer recorder.Stop()
+
+	result := &RunResult{SessionID: recorder.SessionID(), RunAt: time.Now(), Previous: s.LastSummary}
+	timeout := time.Duration(s.Timeout) * time.Second
+	var summary strings.Builder
+	active := nodes
+	for _, command := range Commands(s) {
+		recorder.RecordCommand(command)
+		startTime := time.Now()
+		results := sm.RunCommand(active, command, timeout)
+		session.RecordResults(recorder, results, time.Since(startTime))
+
+		result.Steps = append(result.Steps, Step{Command: command, Nodes: active, Results: results})
+		fmt.Fprintf(&summary, "$ %s\n%s", command, session.MergedSummary(active, results, normalizer))
+
+		var succeeded []model.Node
+		for _, node := range active {
+			if results[node.IP].Success {
+				succeeded = append(succeeded, node)
+			}
+		}
+		if active = succeeded; len(active) == 0 {
+			break
+		}
+	}
+
+	result.Summary = summary.String()
+	result.Changed = s.LastSummary != "" && result.Summary != s.LastSummary
+	if err := crud.UpdateScheduleRun(s.Name, result.RunAt, result.SessionID, result.Summary, result.Changed); err != nil {
+		return result, fmt.Errorf("保存执行结果失败: %v", err)
+	}
+
+	if result.Changed {
+		result.NotifyErr = notify(s, group.Name, result)
+	}
+	return result, nil
+}
+
+// notification 是结果变化时发送给 webhook 的内容
+type notification struct {
+	Schedule  string    `json:"schedule"`
+	Group     string    `json:"group"`
+	SessionID string    `json:"session_id"`
+	RunAt     time.Time `json:"run_at"`
+	Previous  string    `json:"previous"`
+	Current   string    `json:"current"`
+	Diff      string    `json:"diff"`
+}
+
+// notify 执行通知命令并调用 webhook，两者都配置时都会执行
+// 通知命令通过环境变量获取任务信息，从标准输入读取两次合并结果的差异
+func notify(s model.Schedule, groupName string, result *RunResult) error {
+	diff, _ := utils.UnifiedDiff("上次", "本次", result.Previous, result.Summary, 3)
+	var errs []string
+
+	if s.NotifyCommand != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "sh", "-c", s.NotifyCommand)
+		cmd.Env = append(os.Environ(),
+			"CM_SCHEDULE="+s.Name,
+			"CM_GROUP="+groupName,
+			"CM_SESSION="+result.SessionID,
+		)
+		cmd.Stdin = strings.NewReader(diff)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			errs = append(errs, fmt.Sprintf("通知命令执行失败: %v %s", err, bytes.TrimSpace(output)))
+		}
+	}
+
+	if s.NotifyWebhook != "" {
+		body, _ := json.Marshal(notification{
+			Schedule:  s.Name,
+			Group:     groupName,
+			SessionID: result.SessionID,
+			RunAt:     result.RunAt,
+			Previous:  result.Previous,
+			Current:   result.Summary,
+			Diff:      diff,
+		})
+		client := &http.Client{Timeout: notifyTimeout}
+		resp, err := client.Post(s.NotifyWebhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("调用 webhook 失败: %v", err))
+		} else {
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				errs = append(errs, fmt.Sprintf("webhook 返回 %s", resp.Status))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package users
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Identity 表示账户的 UID 和 GID
+type Identity struct {
+	UID int
+	GID int
+}
+
+func (id Identity) String() string {
+	return fmt.Sprintf("uid=%d gid=%d", id.UID, id.GID)
+}
+
+// ParseIdentity 解析 InspectCommand 的输出
+// 第二个返回值表示账户是否存在
+func ParseIdentity(output string) (Identity, bool, error) {
+	output = strings.TrimSpace(output)
+	if output == "missing" {
+		return Identity{}, false, nil
+	}
+
+	var id Identity
+	if _, err := fmt.Sscanf(output, "%d:%d", &id.UID, &id.GID); err != nil {
+		return Identity{}, false, fmt.Errorf("无法解析账户信息: %q", output)
+	}
+	return id, true, nil
+}
+
+// MajorityIdentity 返回出现次数最多的身份，次数相同时取 UID 较小者保证结果稳定
+func MajorityIdentity(ids map[string]Identity) (Identity, bool) {
+	counts := make(map[Identity]int)
+	for _, id := range ids {
+		counts[id]++
+	}
+	if len(counts) == 0 {
+		return Identity{}, false
+	}
+
+	candidates := make([]Identity, 0, len(counts))
+	for id := range counts {
+		candidates = append(candidates, id)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if a.UID != b.UID {
+			return a.UID < b.UID
+		}
+		return a.GID < b.GID
+	})
+	return candidates[0], true
+}
@@ -0,0 +1,124 @@
+package users
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/utils"
+)
+
+// 节点用户不是 root 时通过 sudo 执行管理命令
+const sudoPrefix = `SUDO=; [ "$(id -u)" -ne 0 ] && SUDO="sudo -n"; `
+
+// 合法的 Unix 用户名和组名
+var namePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]*[$]?$`)
+
+// Account 描述要在集群上管理的账户
+type Account struct {
+	Name   string
+	UID    int // 0 表示由系统分配
+	GID    int // 0 表示由系统分配
+	Shell  string
+	Groups []string // 附加组
+}
+
+// ValidateName 校验用户名或组名
+func ValidateName(name string) error {
+	if len(name) > 32 || !namePattern.MatchString(name) {
+		return fmt.Errorf("无效的名称: %s", name)
+	}
+	return nil
+}
+
+// CreateCommand 生成幂等的创建用户命令，用户已存在时只输出 exists
+func CreateCommand(account Account) string {
+	name := utils.ShellQuote(account.Name)
+
+	var opts []string
+	opts = append(opts, "-m")
+	if account.UID > 0 {
+		opts = append(opts, fmt.Sprintf("-u %d", account.UID))
+	}
+	if account.GID > 0 {
+		opts = append(opts, fmt.Sprintf("-g %d", account.GID))
+	}
+	if account.Shell != "" {
+		opts = append(opts, "-s "+utils.ShellQuote(account.Shell))
+	}
+	if len(account.Groups) > 0 {
+		opts = append(opts, "-G "+utils.ShellQuote(strings.Join(account.Groups, ",")))
+	}
+
+	var b strings.Builder
+	b.WriteString(sudoPrefix)
+	b.WriteString(fmt.Sprintf("if id -u %s >/dev/null 2>&1; then echo exists; else ", name))
+	// 指定 GID 时先确保同名主组存在
+	if account.GID > 0 {
+		b.WriteString(fmt.Sprintf("getent group %d >/dev/null || $SUDO groupadd -g %d %s; ", account.GID, account.GID, name))
+	}
+	b.WriteString(ensureGroupsCommand(account.Groups))
+	b.WriteString(fmt.Sprintf("$SUDO useradd %s %s && echo created; fi", strings.Join(opts, " "), name))
+	return b.String()
+}
+
+// DeleteCommand 生成删除用户的命令，同时移除其 sudoers 配置
+func DeleteCommand(name string, removeHome bool) string {
+	q := utils.ShellQuote(name)
+	flag := ""
+	if removeHome {
+		flag = "-r "
+	}
+	return sudoPrefix + fmt.Sprintf(
+		"if id -u %s >/dev/null 2>&1; then $SUDO userdel %s%s && $SUDO rm -f %s && echo deleted; else echo absent; fi",
+		q, flag, q, utils.ShellQuote(sudoersPath(name)))
+}
+
+// PasswordCommand 生成设置用户密码的命令
+func PasswordCommand(name, password string) string {
+	return sudoPrefix + fmt.Sprintf("echo %s | $SUDO chpasswd && echo updated",
+		utils.ShellQuote(name+":"+password))
+}
+
+// AddGroupsCommand 生成将用户加入附加组的命令，不存在的组会被创建
+func AddGroupsCommand(name string, groups []string) string {
+	return sudoPrefix + ensureGroupsCommand(groups) +
+		fmt.Sprintf("$SUDO usermod -aG %s %s && id -nG %s",
+			utils.ShellQuote(strings.Join(groups, ",")), utils.ShellQuote(name), utils.ShellQuote(name))
+}
+
+// SudoCommand 生成写入 sudoers 配置片段的命令
+// 先在 sudoers.d 中用 mktemp 创建临时文件（文件名含 '.'，sudo 会忽略），用 visudo 校验通过后才改名安装，避免破坏 sudo
+func SudoCommand(name, rule string) string {
+	line := fmt.Sprintf("%s %s", name, rule)
+	return sudoPrefix + fmt.Sprintf(
+		"tmp=$($SUDO mktemp /etc/sudoers.d/.talko.XXXXXX) && "+
+			`printf '%%s\n' %s | $SUDO tee "$tmp" > /dev/null && $SUDO chmod 0440 "$tmp" && $SUDO visudo -cqf "$tmp" && `+
+			`$SUDO mv -f "$tmp" %s && echo installed; rc=$?; $SUDO rm -f "$tmp"; [ $rc -eq 0 ]`,
+		utils.ShellQuote(line), utils.ShellQuote(sudoersPath(name)))
+}
+
+// RemoveSudoCommand 生成移除 sudoers 配置片段的命令
+func RemoveSudoCommand(name string) string {
+	return sudoPrefix + fmt.Sprintf("$SUDO rm -f %s && echo removed", utils.ShellQuote(sudoersPath(name)))
+}
+
+// InspectCommand 生成查询用户 UID/GID 的命令，输出格式为 uid:gid，用户不存在时输出 missing
+func InspectCommand(name string) string {
+	return fmt.Sprintf("getent passwd %s | cut -d: -f3,4 | grep . || echo missing", utils.ShellQuote(name))
+}
+
+// ensureGroupsCommand 生成确保附加组存在的命令片段
+func ensureGroupsCommand(groups []string) string {
+	var b strings.Builder
+	for _, g := range groups {
+		q := utils.ShellQuote(g)
+		b.WriteString(fmt.Sprintf("getent group %s >/dev/null || $SUDO groupadd %s; ", q, q))
+	}
+	return b.String()
+}
+
+// sudoersPath 返回用户 sudoers 配置片段的路径
+func sudoersPath(name string) string {
+	// sudoers.d 会忽略包含 '.' 的文件名
+	return "/etc/sudoers.d/" + strings.ReplaceAll(name, ".", "_")
+}
@@ -0,0 +1,391 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"zhaowanpeng/cluster-manager/model"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+// 广播模式的热键前缀 Ctrl-]，之后的一个按键作为广播模式的命令
+const broadcastEscape = 0x1d
+
+// broadcastScrollback 每个节点保留的最近输出字节数，切换焦点时重放
+const broadcastScrollback = 16 * 1024
+
+// broadcastInputQueue 每个节点缓冲的待发送输入块数，积压超过该值的节点停止接收输入
+const broadcastInputQueue = 256
+
+// broadcastHelp 广播模式的热键说明
+var broadcastHelp = []struct{ key, help string }{
+	{"Ctrl-] n", "焦点切换到下一个节点"},
+	{"Ctrl-] p", "焦点切换到上一个节点"},
+	{"Ctrl-] t", "切换焦点节点是否接收输入"},
+	{"Ctrl-] a", "输入发送到所有节点"},
+	{"Ctrl-] o", "输入只发送到焦点节点"},
+	{"Ctrl-] Ctrl-]", "发送 Ctrl-] 本身"},
+	{"Ctrl-] q", "退出广播模式"},
+}
+
+// broadcastNode 是广播模式中一个节点的交互式终端
+type broadcastNode struct {
+	node    model.Node
+	session *ssh.Session
+	stdin   io.WriteCloser
+	stdout  io.Reader
+	input   chan []byte // 待写入 stdin 的输入，由 writer 单独写入，避免一个节点阻塞其他节点
+	enabled bool        // 是否接收键盘输入
+	done    bool        // 远程shell是否已退出
+	recent  []byte      // 最近的输出，切换焦点时重放
+}
+
+// broadcaster 将本地键盘输入广播到多个节点的伪终端，并在本地显示焦点节点的屏幕
+type broadcaster struct {
+	mu     sync.Mutex
+	nodes  []*broadcastNode
+	focus  int
+	closed bool
+	out    io.Writer
+}
+
+// RunBroadcast 在每个节点上开启独立的交互式shell，进入原始终端模式广播键盘输入
+// 节点需要能够直接连接；本地标准输入输出不是终端时返回错误
+func RunBroadcast(sm *SessionManager, nodes []model.Node) error {
+	stdinFd := int(os.Stdin.Fd())
+	stdoutFd := int(os.Stdout.Fd())
+	if !term.IsTerminal(stdinFd) || !term.IsTerminal(stdoutFd) {
+		return fmt.Errorf("广播模式需要在终端中运行")
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("没有可广播的节点")
+	}
+
+	width, height, err := term.GetSize(stdoutFd)
+	if err != nil {
+		width, height = 80, 24
+	}
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm"
+	}
+
+	// 并发打开终端，保持节点原有顺序
+	opened := make([]*broadcastNode, len(nodes))
+	var mutex sync.Mutex
+	var failed []string
+	sm.Limiter().Run(len(nodes), func(i int) {
+		bn, err := openBroadcastNode(sm, nodes[i], termType, width, height)
+		if err != nil {
+			mutex.Lock()
+			failed = append(failed, fmt.Sprintf("打开节点 %s 的终端失败: %v", nodes[i].IP, err))
+			mutex.Unlock()
+			return
+		}
+		opened[i] = bn
+	})
+
+	b := &broadcaster{out: os.Stdout}
+	for _, bn := range opened {
+		if bn != nil {
+			b.nodes = append(b.nodes, bn)
+		}
+	}
+	defer b.close()
+
+	for _, msg := range failed {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	if len(b.nodes) == 0 {
+		return fmt.Errorf("没有节点成功打开终端")
+	}
+
+	fmt.Printf("进入广播模式，共 %d 个节点，焦点节点 %s\n", len(b.nodes), b.nodes[0].node.IP)
+	for _, h := range broadcastHelp {
+		fmt.Printf("  %-16s %s\n", h.key, h.help)
+	}
+
+	oldState, err := term.MakeRaw(stdinFd)
+	if err != nil {
+		return fmt.Errorf("切换到原始终端模式失败: %v", err)
+	}
+	defer term.Restore(stdinFd, oldState)
+
+	for i := range b.nodes {
+		go b.pump(i)
+		go b.nodes[i].writer()
+	}
+
+	stopResize := watchResize(func() {
+		if width, height, err := term.GetSize(stdoutFd); err == nil {
+			b.resize(width, height)
+		}
+	})
+	defer stopResize()
+
+	b.readInput(os.Stdin)
+	return nil
+}
+
+// openBroadcastNode 在节点已有的连接上开启新的伪终端和shell，与执行命令的长会话互不影响
+func openBroadcastNode(sm *SessionManager, node model.Node, termType string, width, height int) (*broadcastNode, error) {
+	ns, err := sm.GetOrCreateSession(node)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := ns.Client().NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("创建输入管道失败: %v", err)
+	}
+	// 伪终端中标准错误与标准输出合并，只需读取标准输出
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, fmt.Errorf("创建输出管道失败: %v", err)
+	}
+	if err := session.RequestPty(termType, height, width, ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("请求伪终端失败: %v", err)
+	}
+	if err := session.Shell(); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("启动shell失败: %v", err)
+	}
+
+	return &broadcastNode{
+		node:    node,
+		session: session,
+		stdin:   stdin,
+		stdout:  stdout,
+		input:   make(chan []byte, broadcastInputQueue),
+		enabled: true,
+	}, nil
+}
+
+// writer 将排队的输入依次写入节点，输入通道关闭后结束
+func (bn *broadcastNode) writer() {
+	for data := range bn.input {
+		bn.stdin.Write(data)
+	}
+}
+
+// pump 读取节点的输出，保留最近的部分，焦点节点的输出直接显示
+func (b *broadcaster) pump(index int) {
+	bn := b.nodes[index]
+	buf := make([]byte, 4096)
+	for {
+		n, err := bn.stdout.Read(buf)
+		if n > 0 {
+			b.mu.Lock()
+			bn.recent = append(bn.recent, buf[:n]...)
+			if over := len(bn.recent) - broadcastScrollback; over > 0 {
+				bn.recent = append(bn.recent[:0], bn.recent[over:]...)
+			}
+			if !b.closed && b.focus == index {
+				b.out.Write(buf[:n])
+			}
+			b.mu.Unlock()
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	bn.done = true
+	if b.closed {
+		return
+	}
+	b.statusf("节点 %s 的会话已结束", bn.node.IP)
+	if b.allDone() {
+		b.statusf("所有节点的会话已结束，按任意键返回")
+	}
+}
+
+// readInput 读取本地键盘输入并发送到接收输入的节点，直到退出或所有会话结束
+func (b *broadcaster) readInput(in io.Reader) {
+	buf := make([]byte, 1024)
+	escaped := false
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+
+		var data []byte
+		for _, c := range buf[:n] {
+			if escaped {
+				escaped = false
+				if c == broadcastEscape {
+					data = append(data, c)
+					continue
+				}
+				b.send(data)
+				data = nil
+				if b.hotkey(c) {
+					return
+				}
+				continue
+			}
+			if c == broadcastEscape {
+				escaped = true
+				continue
+			}
+			data = append(data, c)
+		}
+		b.send(data)
+
+		b.mu.Lock()
+		done := b.allDone()
+		b.mu.Unlock()
+		if done {
+			return
+		}
+	}
+}
+
+// send 将输入放入所有接收输入且仍在运行的节点的队列，不等待写入完成
+// 队列已满说明节点长时间不读取输入，该节点停止接收输入，避免拖慢其他节点
+func (b *broadcaster) send(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	for _, bn := range b.nodes {
+		if !bn.enabled || bn.done {
+			continue
+		}
+		select {
+		case bn.input <- append([]byte(nil), data...):
+		default:
+			bn.enabled = false
+			b.statusf("节点 %s 输入积压，已停止向其发送输入，可按 Ctrl-] a 恢复", bn.node.IP)
+		}
+	}
+}
+
+// hotkey 处理热键前缀之后的按键，返回是否退出广播模式
+func (b *broadcaster) hotkey(c byte) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch c {
+	case 'q', 'Q':
+		return true
+	case 'n', 'N', '\t':
+		b.setFocus(b.focus + 1)
+	case 'p', 'P':
+		b.setFocus(b.focus - 1)
+	case 't', 'T':
+		bn := b.nodes[b.focus]
+		bn.enabled = !bn.enabled
+		b.showStatus()
+	case 'a', 'A':
+		for _, bn := range b.nodes {
+			bn.enabled = true
+		}
+		b.showStatus()
+	case 'o', 'O':
+		for i, bn := range b.nodes {
+			bn.enabled = i == b.focus
+		}
+		b.showStatus()
+	default:
+		for _, h := range broadcastHelp {
+			b.statusf("%-16s %s", h.key, h.help)
+		}
+	}
+	return false
+}
+
+// setFocus 切换焦点节点，清屏后重放该节点最近的输出，调用方需持有锁
+func (b *broadcaster) setFocus(index int) {
+	n := len(b.nodes)
+	b.focus = (index%n + n) % n
+	bn := b.nodes[b.focus]
+
+	// 清屏并重放最近的输出；全屏程序可按 Ctrl-L 等方式完整重绘
+	io.WriteString(b.out, "\x1b[H\x1b[2J")
+	b.out.Write(bn.recent)
+	b.showStatus()
+}
+
+// showStatus 显示焦点节点和接收输入的节点数，调用方需持有锁
+func (b *broadcaster) showStatus() {
+	enabled := 0
+	for _, bn := range b.nodes {
+		if bn.enabled && !bn.done {
+			enabled++
+		}
+	}
+	bn := b.nodes[b.focus]
+	state := "接收输入"
+	if !bn.enabled {
+		state = "不接收输入"
+	}
+	b.statusf("焦点 %s (%d/%d, %s)，输入发送到 %d 个节点", bn.node.IP, b.focus+1, len(b.nodes), state, enabled)
+}
+
+// statusf 以反色显示一行广播模式的状态信息，调用方需持有锁
+// 原始终端模式下换行不会回到行首，需要显式输出 \r
+func (b *broadcaster) statusf(format string, args ...interface{}) {
+	fmt.Fprintf(b.out, "\r\n\x1b[7m[广播] %s\x1b[0m\r\n", fmt.Sprintf(format, args...))
+}
+
+// allDone 返回是否所有节点的会话都已结束，调用方需持有锁
+func (b *broadcaster) allDone() bool {
+	for _, bn := range b.nodes {
+		if !bn.done {
+			return false
+		}
+	}
+	return true
+}
+
+// resize 将本地终端大小同步到每个节点的伪终端
+// 锁内只取出仍在运行的会话，每个节点在单独的 goroutine 中发送，无响应的节点不影响其他节点和输入输出
+func (b *broadcaster) resize(width, height int) {
+	b.mu.Lock()
+	var sessions []*ssh.Session
+	for _, bn := range b.nodes {
+		if !bn.done {
+			sessions = append(sessions, bn.session)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, session := range sessions {
+		go session.WindowChange(height, width)
+	}
+}
+
+// close 关闭所有节点的终端，之后到达的输出不再显示
+func (b *broadcaster) close() {
+	b.mu.Lock()
+	b.closed = true
+	b.mu.Unlock()
+
+	for _, bn := range b.nodes {
+		close(bn.input)
+		bn.stdin.Close()
+		bn.session.Close()
+	}
+}
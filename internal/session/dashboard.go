@@ -0,0 +1,633 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/facts"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+	"golang.org/x/term"
+)
+
+// dashboardProbe 在节点上输出一行以 CMTOP 开头的状态：1分钟负载、CPU总时间、CPU空闲时间、内存总量(KB)、可用内存(KB)、根分区使用率
+// 在子shell中执行，不影响长会话的变量和位置参数
+const dashboardProbe = `(read l _ < /proc/loadavg; set -- $(head -n 1 /proc/stat); shift; t=0; for v in "$@"; do t=$((t + v)); done; ` +
+	`echo "CMTOP $l $t $(($4 + $5)) $(awk '/^MemTotal:/ {t=$2} /^MemAvailable:/ {a=$2} END {print t+0, a+0}' /proc/meminfo) ` +
+	`$(df -P / 2>/dev/null | awk 'NR == 2 {sub("%", "", $5); print $5}')")`
+
+// 仪表盘中的终端控制序列
+const (
+	dashboardEnter = "\x1b[?1049h\x1b[?25l\x1b[?7l" // 切换到备用屏幕、隐藏光标、关闭自动换行
+	dashboardLeave = "\x1b[?7h\x1b[?25h\x1b[?1049l"
+)
+
+// 仪表盘的列，数字键 1-7 按对应列排序
+const (
+	colNode = iota
+	colStatus
+	colLoad
+	colCPU
+	colMem
+	colDisk
+	colLatency
+)
+
+// dashboardColumns 列标题和宽度，宽度包含列之间的空格
+var dashboardColumns = []struct {
+	title string
+	width int
+}{
+	{"NODE", 17}, {"STATUS", 10}, {"LOAD", 8}, {"CPU%", 8}, {"MEM%", 8}, {"DISK%", 9}, {"LATENCY", 11},
+}
+
+// 节点状态
+const (
+	nodePending = iota // 还没有采集过
+	nodeDown
+	nodeUp
+)
+
+// nodeStat 是节点最近一次采集的状态，数值未知时为 -1
+type nodeStat struct {
+	state    int
+	err      string
+	load     float64
+	cpu      float64
+	mem      float64
+	memUsed  int64 // KB
+	memTotal int64 // KB
+	disk     float64
+	latency  time.Duration
+
+	// 上一次采集的 CPU 时间，用于计算两次采集之间的使用率
+	cpuTotal uint64
+	cpuIdle  uint64
+}
+
+// dashboard 是类似 top 的全屏节点状态面板
+type dashboard struct {
+	mu       sync.Mutex
+	sm       *SessionManager
+	group    string
+	nodes    []model.Node
+	stats    map[string]*nodeStat
+	interval time.Duration
+	timeout  time.Duration
+
+	sortCol  int
+	reverse  bool
+	selected string // 选中节点的IP
+	offset   int    // 节点列表滚动的行数
+	updated  time.Time
+	sampling bool
+	paused   bool // 进入节点shell期间不刷新屏幕
+	closed   bool
+	refresh  chan struct{}
+}
+
+// RunDashboard 打开组内节点的全屏状态面板，每隔 interval 通过长会话采集负载、CPU、内存和磁盘使用率
+// 数字键按列排序，回车进入选中节点的shell，q 退出；本地标准输入输出不是终端时返回错误
+func RunDashboard(options ExecOptions, interval time.Duration) error {
+	stdinFd := int(os.Stdin.Fd())
+	stdoutFd := int(os.Stdout.Fd())
+	if !term.IsTerminal(stdinFd) || !term.IsTerminal(stdoutFd) {
+		return fmt.Errorf("状态面板需要在终端中运行")
+	}
+
+	group, nodes, err := ResolveNodes(options)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		return fmt.Errorf("组 '%s' 中没有节点", group.Name)
+	}
+
+	sm := NewSessionManager()
+	defer sm.CloseAll()
+	sm.SetLimiter(fanout.ForGroup(group, options.Fanout, options.DialRate))
+
+	d := &dashboard{
+		sm:       sm,
+		group:    group.Name,
+		nodes:    sortNodes(nodes, nil, SortIP),
+		stats:    make(map[string]*nodeStat, len(nodes)),
+		interval: interval,
+		timeout:  options.Timeout,
+		sortCol:  colNode,
+		refresh:  make(chan struct{}, 1),
+	}
+	for _, node := range nodes {
+		d.stats[node.IP] = &nodeStat{load: -1, cpu: -1, mem: -1, disk: -1}
+	}
+	d.selected = d.nodes[0].IP
+
+	oldState, err := term.MakeRaw(stdinFd)
+	if err != nil {
+		return fmt.Errorf("切换到原始终端模式失败: %v", err)
+	}
+	fmt.Print(dashboardEnter)
+	defer func() {
+		fmt.Print(dashboardLeave)
+		term.Restore(stdinFd, oldState)
+	}()
+
+	stopResize := watchResize(func() {
+		d.mu.Lock()
+		d.draw()
+		d.mu.Unlock()
+	})
+	defer stopResize()
+
+	d.mu.Lock()
+	d.draw()
+	d.mu.Unlock()
+	go d.loop()
+
+	d.readKeys(stdinFd, oldState)
+
+	d.mu.Lock()
+	d.closed = true
+	d.mu.Unlock()
+	return nil
+}
+
+// loop 定期采集节点状态并刷新屏幕，也可以通过 refresh 立即采集
+func (d *dashboard) loop() {
+	for {
+		d.mu.Lock()
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		d.sampling = true
+		d.draw()
+		d.mu.Unlock()
+
+		results := d.sm.RunCommand(d.nodes, dashboardProbe, d.timeout)
+
+		d.mu.Lock()
+		if d.closed {
+			d.mu.Unlock()
+			return
+		}
+		d.update(results)
+		d.sampling = false
+		d.draw()
+		d.mu.Unlock()
+
+		select {
+		case <-time.After(d.interval):
+		case <-d.refresh:
+		}
+	}
+}
+
+// update 根据采集结果更新节点状态，调用方需持有锁
+func (d *dashboard) update(results map[string]ExecResult) {
+	d.updated = time.Now()
+	for _, node := range d.nodes {
+		st := d.stats[node.IP]
+		result, ok := results[node.IP]
+		if !ok {
+			continue
+		}
+		if !result.Success {
+			st.state, st.err = nodeDown, resultError(result)
+			st.load, st.cpu, st.mem, st.disk, st.latency = -1, -1, -1, -1, 0
+			st.cpuTotal, st.cpuIdle = 0, 0
+			continue
+		}
+		if err := st.parse(result.Output); err != nil {
+			st.state, st.err = nodeDown, err.Error()
+			continue
+		}
+		st.state, st.err = nodeUp, ""
+		st.latency = result.Duration
+	}
+}
+
+// parse 解析探测命令的输出，CPU 使用率为与上一次采集之间的平均值
+func (st *nodeStat) parse(output string) error {
+	var fields []string
+	for _, line := range strings.Split(output, "\n") {
+		if f := strings.Fields(line); len(f) > 0 && f[0] == "CMTOP" {
+			fields = f[1:]
+		}
+	}
+	if len(fields) < 5 {
+		return fmt.Errorf("无法解析节点状态: %s", strings.TrimSpace(output))
+	}
+
+	load, _ := strconv.ParseFloat(fields[0], 64)
+	total, _ := strconv.ParseUint(fields[1], 10, 64)
+	idle, _ := strconv.ParseUint(fields[2], 10, 64)
+	memTotal, _ := strconv.ParseInt(fields[3], 10, 64)
+	memAvail, _ := strconv.ParseInt(fields[4], 10, 64)
+
+	st.load = load
+	st.cpu = -1
+	if st.cpuTotal > 0 && total > st.cpuTotal && idle >= st.cpuIdle {
+		dt, di := total-st.cpuTotal, idle-st.cpuIdle
+		if di <= dt {
+			st.cpu = float64(dt-di) * 100 / float64(dt)
+		}
+	}
+	st.cpuTotal, st.cpuIdle = total, idle
+
+	st.mem, st.memUsed, st.memTotal = -1, 0, memTotal
+	if memTotal > 0 {
+		st.memUsed = memTotal - memAvail
+		st.mem = float64(st.memUsed) * 100 / float64(memTotal)
+	}
+
+	st.disk = -1
+	if len(fields) > 5 {
+		if disk, err := strconv.ParseFloat(fields[5], 64); err == nil {
+			st.disk = disk
+		}
+	}
+	return nil
+}
+
+// resultError 返回失败结果的简短说明
+func resultError(result ExecResult) string {
+	switch {
+	case result.Error != nil:
+		return result.Error.Error()
+	case result.TimedOut:
+		return "采集超时"
+	}
+	return fmt.Sprintf("退出码 %d", result.ExitCode)
+}
+
+// readKeys 读取键盘输入直到退出，是唯一读取标准输入的地方
+func (d *dashboard) readKeys(stdinFd int, oldState *term.State) {
+	buf := make([]byte, 64)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil || n == 0 {
+			return
+		}
+
+		for _, key := range splitKeys(buf[:n]) {
+			switch key {
+			case "q", "Q", "\x03", "\x04":
+				return
+			case "\r", "\n":
+				d.shell(stdinFd, oldState)
+				continue
+			case "r":
+				select {
+				case d.refresh <- struct{}{}:
+				default:
+				}
+				continue
+			}
+
+			d.mu.Lock()
+			switch key {
+			case "j", "\x1b[B", "\x1bOB":
+				d.move(1)
+			case "k", "\x1b[A", "\x1bOA":
+				d.move(-1)
+			case "g", "\x1b[H", "\x1b[1~":
+				d.move(-len(d.nodes))
+			case "G", "\x1b[F", "\x1b[4~":
+				d.move(len(d.nodes))
+			case "s":
+				d.setSort((d.sortCol + 1) % len(dashboardColumns))
+			default:
+				if len(key) == 1 && key[0] >= '1' && int(key[0]-'1') < len(dashboardColumns) {
+					d.setSort(int(key[0] - '1'))
+				}
+			}
+			d.draw()
+			d.mu.Unlock()
+		}
+	}
+}
+
+// splitKeys 将一次读到的输入拆分为单个按键，方向键等转义序列作为一个按键
+func splitKeys(input []byte) []string {
+	var keys []string
+	for len(input) > 0 {
+		n := 1
+		if input[0] == 0x1b && len(input) > 2 && (input[1] == '[' || input[1] == 'O') {
+			n = 2
+			for n < len(input) {
+				n++
+				// CSI 序列以 0x40-0x7e 之间的字节结束，SS3 序列只有一个字节
+				if input[1] == 'O' || input[n-1] >= 0x40 && input[n-1] <= 0x7e {
+					break
+				}
+			}
+		}
+		keys = append(keys, string(input[:n]))
+		input = input[n:]
+	}
+	return keys
+}
+
+// setSort 按列排序，再次选择同一列时反转顺序；数值列默认从大到小
+func (d *dashboard) setSort(col int) {
+	if col == d.sortCol {
+		d.reverse = !d.reverse
+		return
+	}
+	d.sortCol = col
+	d.reverse = col != colNode && col != colStatus
+}
+
+// move 将选中行移动 delta 行
+func (d *dashboard) move(delta int) {
+	order := d.order()
+	i := 0
+	for j, node := range order {
+		if node.IP == d.selected {
+			i = j
+		}
+	}
+	i += delta
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(order) {
+		i = len(order) - 1
+	}
+	d.selected = order[i].IP
+}
+
+// shell 暂停刷新，离开全屏界面进入选中节点的交互式shell，退出shell后回到面板
+func (d *dashboard) shell(stdinFd int, oldState *term.State) {
+	d.mu.Lock()
+	d.paused = true
+	var node model.Node
+	for _, n := range d.nodes {
+		if n.IP == d.selected {
+			node = n
+		}
+	}
+	d.mu.Unlock()
+
+	fmt.Print(dashboardLeave)
+	term.Restore(stdinFd, oldState)
+
+	if err := RunBroadcast(d.sm, []model.Node{node}); err != nil {
+		color.Red("%v", err)
+		fmt.Print("按回车键返回状态面板")
+		fmt.Scanln()
+	}
+
+	term.MakeRaw(stdinFd)
+	fmt.Print(dashboardEnter)
+	d.mu.Lock()
+	d.paused = false
+	d.draw()
+	d.mu.Unlock()
+}
+
+// order 返回按子网分组、子网内按当前排序列排列的节点
+func (d *dashboard) order() []model.Node {
+	bySubnet := groupNodesBySubnet(d.nodes)
+	order := make([]model.Node, 0, len(d.nodes))
+	for _, subnet := range sortedSubnets(bySubnet) {
+		nodes := bySubnet[subnet]
+		sort.SliceStable(nodes, func(i, j int) bool {
+			c := d.compare(nodes[i], nodes[j])
+			if d.reverse {
+				c = -c
+			}
+			if c == 0 {
+				return compareIP(nodes[i].IP, nodes[j].IP) < 0
+			}
+			return c < 0
+		})
+		order = append(order, nodes...)
+	}
+	return order
+}
+
+// compare 按当前排序列比较两个节点
+func (d *dashboard) compare(a, b model.Node) int {
+	sa, sb := d.stats[a.IP], d.stats[b.IP]
+	var x, y float64
+	switch d.sortCol {
+	case colNode:
+		return compareIP(a.IP, b.IP)
+	case colStatus:
+		x, y = float64(sa.state), float64(sb.state)
+	case colLoad:
+		x, y = sa.load, sb.load
+	case colCPU:
+		x, y = sa.cpu, sb.cpu
+	case colMem:
+		x, y = sa.mem, sb.mem
+	case colDisk:
+		x, y = sa.disk, sb.disk
+	case colLatency:
+		x, y = float64(sa.latency), float64(sb.latency)
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+// draw 重绘整个屏幕，调用方需持有锁
+func (d *dashboard) draw() {
+	if d.paused || d.closed {
+		return
+	}
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	up, down := 0, 0
+	for _, st := range d.stats {
+		switch st.state {
+		case nodeUp:
+			up++
+		case nodeDown:
+			down++
+		}
+	}
+	status := "等待采集"
+	if !d.updated.IsZero() {
+		status = "更新于 " + d.updated.Format("15:04:05")
+	}
+	if d.sampling {
+		status += "，采集中..."
+	}
+	header := fmt.Sprintf("组 %s  节点 %d  %s  %s  %s  刷新间隔 %s  %s",
+		d.group, len(d.nodes), color.GreenString("可用 %d", up), color.RedString("不可用 %d", down),
+		color.HiBlackString("未知 %d", len(d.nodes)-up-down), d.interval, status)
+
+	var titles strings.Builder
+	for i, c := range dashboardColumns {
+		title := c.title
+		if i == d.sortCol {
+			if d.reverse {
+				title += "▼"
+			} else {
+				title += "▲"
+			}
+		}
+		titles.WriteString(fmt.Sprintf("%-*s", c.width, fmt.Sprintf("%d:%s", i+1, title)))
+	}
+	titles.WriteString("MEMORY/ERROR")
+
+	// 节点列表，子网标题行不可选中
+	var body []string
+	selectedLine := 0
+	bySubnet := groupNodesBySubnet(d.nodes)
+	order := d.order()
+	for i, node := range order {
+		if i == 0 || SubnetKey(order[i-1].IP) != SubnetKey(node.IP) {
+			subnet := SubnetKey(node.IP)
+			body = append(body, color.CyanString("── %s.0/24 (%d)", subnet, len(bySubnet[subnet])))
+		}
+		if node.IP == d.selected {
+			selectedLine = len(body)
+		}
+		body = append(body, d.row(node, width))
+	}
+
+	// 保证选中行在可见范围内
+	visible := height - 3
+	if visible < 1 {
+		visible = 1
+	}
+	if selectedLine < d.offset {
+		d.offset = selectedLine
+	}
+	if selectedLine >= d.offset+visible {
+		d.offset = selectedLine - visible + 1
+	}
+	if d.offset > len(body)-visible {
+		d.offset = len(body) - visible
+	}
+	if d.offset < 0 {
+		d.offset = 0
+	}
+	end := d.offset + visible
+	if end > len(body) {
+		end = len(body)
+	}
+
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+	screen.WriteString(header + "\x1b[K\r\n")
+	screen.WriteString(color.New(color.Bold).Sprint(titles.String()) + "\x1b[K\r\n")
+	for _, line := range body[d.offset:end] {
+		screen.WriteString(line + "\x1b[K\r\n")
+	}
+	screen.WriteString("\x1b[J")
+	screen.WriteString(fmt.Sprintf("\x1b[%d;1H", height))
+	screen.WriteString(color.HiBlackString("1-7 排序(再按反向)  s 切换排序列  j/k ↑/↓ 选择  回车 进入节点shell  r 立即刷新  q 退出"))
+	screen.WriteString("\x1b[K")
+	fmt.Print(screen.String())
+}
+
+// row 返回节点的一行，选中的行反色显示
+func (d *dashboard) row(node model.Node, width int) string {
+	st := d.stats[node.IP]
+	selected := node.IP == d.selected
+
+	cells := make([]string, len(dashboardColumns))
+	cells[colNode] = node.IP
+	cells[colLoad] = formatStat(st.load, "%.2f")
+	cells[colCPU] = formatStat(st.cpu, "%.1f")
+	cells[colMem] = formatStat(st.mem, "%.1f")
+	cells[colDisk] = formatStat(st.disk, "%.0f")
+	cells[colLatency] = "-"
+	if st.state == nodeUp {
+		cells[colLatency] = st.latency.Round(time.Millisecond).String()
+	}
+	switch st.state {
+	case nodeUp:
+		cells[colStatus] = "up"
+	case nodeDown:
+		cells[colStatus] = "down"
+	default:
+		cells[colStatus] = "..."
+	}
+
+	var line strings.Builder
+	for i, cell := range cells {
+		padded := fmt.Sprintf("%-*s", dashboardColumns[i].width, cell)
+		if !selected {
+			padded = colorCell(i, st, padded)
+		}
+		line.WriteString(padded)
+	}
+
+	// 最后一列可用内存或错误信息，按剩余宽度截断
+	extra := ""
+	if st.state == nodeDown {
+		extra = st.err
+	} else if st.memTotal > 0 {
+		extra = fmt.Sprintf("%s/%s", facts.FormatMB(st.memUsed/1024), facts.FormatMB(st.memTotal/1024))
+	}
+	fixed := 0
+	for _, c := range dashboardColumns {
+		fixed += c.width
+	}
+	if runes := []rune(strings.ReplaceAll(extra, "\n", " ")); len(runes) > width-fixed {
+		if width-fixed > 0 {
+			extra = string(runes[:width-fixed])
+		} else {
+			extra = ""
+		}
+	}
+	if st.state == nodeDown && !selected {
+		extra = color.RedString(extra)
+	}
+	line.WriteString(extra)
+
+	if selected {
+		return "\x1b[7m" + line.String() + "\x1b[K\x1b[0m"
+	}
+	return line.String()
+}
+
+// colorCell 按数值高低为单元格着色
+func colorCell(col int, st *nodeStat, cell string) string {
+	switch col {
+	case colStatus:
+		switch st.state {
+		case nodeUp:
+			return color.GreenString(cell)
+		case nodeDown:
+			return color.RedString(cell)
+		}
+		return color.HiBlackString(cell)
+	case colCPU, colMem, colDisk:
+		value := map[int]float64{colCPU: st.cpu, colMem: st.mem, colDisk: st.disk}[col]
+		switch {
+		case value >= 90:
+			return color.RedString(cell)
+		case value >= 70:
+			return color.YellowString(cell)
+		}
+	}
+	return cell
+}
+
+// formatStat 格式化数值，未知时显示 -
+func formatStat(value float64, format string) string {
+	if value < 0 {
+		return "-"
+	}
+	return fmt.Sprintf(format, value)
+}
@@ -1,11 +1,16 @@
 package session
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"os/user"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/facts"
 	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
 	"zhaowanpeng/cluster-manager/model"
 
@@ -23,325 +28,277 @@ type ExecOptions struct {
 	Port         int
 	User         string
 	Password     string
+	// CompressThreshold 记录输出时超过该字节数则压缩存储，0 表示不压缩
+	CompressThreshold int
+	// UseGateways 经组配置的子网网关执行命令
+	UseGateways bool
+	// Fanout 并发处理的节点数上限，DialRate 每秒新建连接数上限，0 表示使用组配置或默认值
+	Fanout   int
+	DialRate float64
+	// SortBy 结果排序方式，SortIP、SortDuration 或 SortExitCode，为空时按IP排序
+	SortBy string
+	// DiffOutput 以多数节点的输出为基准，其余输出显示与基准的差异
+	DiffOutput bool
+	// Normalize 合并输出时屏蔽IP、主机名、数字等因节点而异的部分后再分组
+	Normalize bool
+	// OutputFormat 结果显示格式，FormatText 或 FormatJSON
+	OutputFormat string
+	// NoPTY 不分配伪终端执行，分别捕获标准输出和标准错误，命令之间不保留工作目录
+	NoPTY bool
+	// Strategy 分批（滚动/金丝雀）执行策略，未设置时一次在所有节点上执行
+	Strategy Strategy
+	// Where 按节点保存的系统信息筛选节点的选择器，如 os_id=ubuntu,cpus>=8，见 facts 命令
+	Where string
 }
 
 // ExecResult 表示命令执行结果
 type ExecResult struct {
 	Node    model.Node
 	Output  string
+	Stderr  string // 仅在不分配伪终端时单独捕获，伪终端模式下标准错误合并在 Output 中
 	Error   error
 	Success bool
+
+	ExitCode int    // 退出码，命令未执行或未结束时为 -1
+	Signal   string // 远程进程被信号终止时的信号名，如 SIGKILL
+	TimedOut bool   // 命令在超时时间内没有结束
+
+	Hostname string        // 节点主机名，合并输出归一化时使用
+	Duration time.Duration // 命令在节点上的执行耗时
+	Cwd      string        // 命令结束时长会话的工作目录，未分配伪终端时为空
 }
 
-// StartGroupExec 启动组执行会话
-func StartGroupExec(options ExecOptions) error {
-	// 1. 获取组中的节点
-	group, err := crud.GetGroup(options.GroupName)
+// ResolveNodes 返回执行选项指定的组和要执行的节点：组内节点加上 AddNodes，再去掉 ExcludeNodes
+func ResolveNodes(options ExecOptions) (model.Group, []model.Node, error) {
+	group, _, nodes, err := resolveGroupNodes(options, options.GroupName, options.AddNodes, options.ExcludeNodes)
+	return group, nodes, err
+}
+
+// resolveGroupNodes 返回组、组内节点加上 addRange 后的全部节点，以及再去掉 excludeRange 并按 options.Where 筛选后的节点
+func resolveGroupNodes(options ExecOptions, name, addRange, excludeRange string) (model.Group, []model.Node, []model.Node, error) {
+	group, err := crud.GetGroup(name)
 	if err != nil {
-		return fmt.Errorf("获取组信息失败: %v", err)
+		return group, nil, nil, fmt.Errorf("获取组信息失败: %v", err)
 	}
-
-	// 如果组中没有节点，则从组中获取节点
-	nodes, err := crud.GetNodesInGroup(options.GroupName)
+	pool, err := crud.GetNodesInGroup(name)
 	if err != nil {
-		return fmt.Errorf("获取组节点失败: %v", err)
+		return group, nil, nil, fmt.Errorf("获取组节点失败: %v", err)
 	}
 
-	// if len(nodes) == 0 {
-	// 	return fmt.Errorf("组 '%s' 中没有可用节点", options.GroupName)
-	// }
-
-	// 2. 处理添加节点
-	if options.AddNodes != "" {
-		addIPs, err := ip_util.ParseIPRange(options.AddNodes)
+	// 额外添加的节点使用命令行指定的连接信息
+	if addRange != "" {
+		addIPs, err := ip_util.ParseIPRange(addRange)
 		if err != nil {
-			return fmt.Errorf("解析添加节点失败: %v", err)
+			return group, nil, nil, fmt.Errorf("解析添加节点失败: %v", err)
 		}
-
-		// 添加新节点
 		for _, ip := range addIPs {
-			// 检查是否已存在
-			exists := false
-			for _, node := range nodes {
-				if node.IP == ip {
-					exists = true
-					break
-				}
-			}
-
-			if !exists {
-				// 使用组的默认连接信息
-				newNode := model.Node{
-					IP:       ip,
-					Port:     options.Port,
-					User:     options.User,
-					Password: options.Password,
-					Group:    group.Name,
-				}
-				nodes = append(nodes, newNode)
+			if findNode(pool, ip) < 0 {
+				pool = append(pool, newAdHocNode(options, ip, group.Name))
 			}
 		}
 	}
 
-	// 3. 处理排除节点
-	if options.ExcludeNodes != "" {
-		excludeIPs, err := ip_util.ParseIPRange(options.ExcludeNodes)
+	nodes := pool
+	if excludeRange != "" {
+		excludeIPs, err := ip_util.ParseIPRange(excludeRange)
 		if err != nil {
-			return fmt.Errorf("解析排除节点失败: %v", err)
+			return group, nil, nil, fmt.Errorf("解析排除节点失败: %v", err)
 		}
-
-		// 过滤掉排除的节点
-		var filteredNodes []model.Node
-		for _, node := range nodes {
-			if !contains(excludeIPs, node.IP) {
-				filteredNodes = append(filteredNodes, node)
-			}
-		}
-		nodes = filteredNodes
-
+		nodes = filterNodes(pool, excludeIPs, false)
 		if len(nodes) == 0 {
-			return fmt.Errorf("所有节点都被排除了")
+			return group, nil, nil, fmt.Errorf("所有节点都被排除了")
 		}
 	}
 
-	// 4. 创建会话管理器
-	sessionManager := NewSessionManager()
-	defer sessionManager.CloseAll()
-
-	// 5. 预连接所有节点
-	color.Yellow("正在建立SSH连接到所有节点...")
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
-	var failedNodes []string
-
-	for _, node := range nodes {
-		wg.Add(1)
-		go func(node model.Node) {
-			defer wg.Done()
-			_, err := sessionManager.GetOrCreateSession(node)
-			if err != nil {
-				mutex.Lock()
-				failedNodes = append(failedNodes, node.IP)
-				color.Red("连接节点 %s 失败: %v", node.IP, err)
-				mutex.Unlock()
-			}
-		}(node)
-	}
-	wg.Wait()
-
-	// 移除连接失败的节点
-	if len(failedNodes) > 0 {
-		var connectedNodes []model.Node
-		for _, node := range nodes {
-			if !contains(failedNodes, node.IP) {
-				connectedNodes = append(connectedNodes, node)
-			}
+	if options.Where != "" {
+		selector, err := facts.ParseSelector(options.Where)
+		if err != nil {
+			return group, nil, nil, err
 		}
-		nodes = connectedNodes
-	}
-
-	if len(nodes) == 0 {
-		return fmt.Errorf("所有连接都失败了")
+		matched, unknown := selector.Filter(nodes)
+		if len(unknown) > 0 {
+			color.Yellow("跳过没有采集过系统信息的节点: %s（使用 facts 命令采集）", CompressIPList(nodeIPs(unknown)))
+		}
+		if len(matched) == 0 {
+			return group, nil, nil, fmt.Errorf("没有节点满足条件: %s", options.Where)
+		}
+		nodes = matched
 	}
+	return group, pool, nodes, nil
+}
 
-	// 6. 显示连接信息
-	color.Green("已连接到组 '%s' 的 %d 个节点", options.GroupName, len(nodes))
-	// if group.Description != "" {
-	// 	fmt.Printf("描述: %s\n", group.Description)
-	// }
-
-	// 7. 分组显示节点信息
-	// eg:
-	// 192.168.1.1
-	// 192.168.1.2
-	// 192.168.1.3
-	// 192.168.1.4
-	// 192.168.1.5
-	// 192.168.1.6
-	nodesBySubnet := groupNodesBySubnet(nodes)
-	color.Cyan("已连接节点:")
-	for subnet, subnetNodes := range nodesBySubnet {
-		color.Yellow("%s:", subnet)
-		for _, node := range subnetNodes {
-			fmt.Printf("  - %s\n", node.IP)
-		}
+// newAdHocNode 为未保存的IP创建节点，使用命令行指定的连接信息
+func newAdHocNode(options ExecOptions, ip, groupName string) model.Node {
+	return model.Node{
+		ID:       fmt.Sprintf("%s-%s", groupName, ip),
+		IP:       ip,
+		Port:     options.Port,
+		User:     options.User,
+		Password: options.Password,
+		Group:    groupName,
 	}
-	fmt.Println()
+}
 
-	// 8. 创建交互式会话
-	rl, err := readline.New(color.GreenString(options.GroupName) + " > ")
+// StartGroupExec 启动组执行会话
+func StartGroupExec(options ExecOptions) error {
+	r, err := newRepl(options)
 	if err != nil {
-		return fmt.Errorf("创建交互式会话失败: %v", err)
+		return err
 	}
-	defer rl.Close()
+	defer r.close()
 
-	// 命令超时设置
-	timeout := options.Timeout
-
-	// 常见命令的快速超时时间，避免无效等待
-	fastCmds := map[string]bool{
-		"whoami": true, "hostname": true, "uptime": true,
-		"date": true, "pwd": true, "id": true, "echo": true,
-		"ls": true, "ps": true, "df": true, "free": true,
-		"uname": true, "which": true, "type": true,
-	}
+	r.run()
+	return nil
+}
 
-	// 9. 交互式循环
-	for {
-		line, err := rl.Readline()
-		// 如果输入为空，则退出
+// confirmFunc 返回使用交互式会话读取确认输入的函数，读取后恢复为 restore 提示符
+func confirmFunc(rl *readline.Instance, restore string) func(prompt string) bool {
+	return func(prompt string) bool {
+		defer rl.SetPrompt(restore)
+		rl.SetPrompt(color.YellowString(prompt))
+		answer, err := rl.Readline()
 		if err != nil {
-			break
+			return false
 		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes"
+	}
+}
 
-		// 去掉命令前后空白
-		command := strings.TrimSpace(line)
-		if command == "" {
-			continue
-		}
+// executeCommandOnNodes 在所有节点上执行命令
+func executeCommandOnNodes(sessionManager *SessionManager, nodes []model.Node, command string, timeout time.Duration) map[string]ExecResult {
+	results := make(map[string]ExecResult)
+	var mutex sync.Mutex
 
-		// 处理特殊命令
-		if command == "exit" || command == "quit" || command == "ctrl+q" {
-			color.Green("退出会话")
-			break
-		}
+	// 并发度受会话管理器的限制器约束
+	sessionManager.Limiter().Run(len(nodes), func(i int) {
+		node := nodes[i]
+		result := executeCommandOnNode(sessionManager, node, command, timeout)
 
-		if command == "nodes" {
-			// 显示当前连接的节点
-			displayConnectedNodes(nodesBySubnet)
-			continue
-		}
+		mutex.Lock()
+		results[node.IP] = result
+		mutex.Unlock()
+	})
 
-		// 对于一些基本命令使用更短的超时
-		cmdTimeout := timeout
-		cmdParts := strings.Fields(command)
-		if len(cmdParts) > 0 {
-			baseCmd := cmdParts[0]
-			if fastCmds[baseCmd] {
-				cmdTimeout = 5 * time.Second // 快速命令使用5秒超时
-			}
-		}
+	return results
+}
 
-		// 执行命令并收集结果
-		// results := executeCommandOnNodes(sessionManager, nodes, command, options.Timeout)
-		results := executeCommandOnNodes(sessionManager, nodes, command, cmdTimeout)
+// executeCommandOnNode 在单个节点上执行命令
+func executeCommandOnNode(sessionManager *SessionManager, node model.Node, command string, timeout time.Duration) ExecResult {
+	// 获取节点会话
+	session, err := sessionManager.GetOrCreateSession(node)
+	if err != nil {
+		return ExecResult{
+			Node:     node,
+			Output:   "",
+			Error:    fmt.Errorf("获取会话失败: %v", err),
+			Success:  false,
+			ExitCode: -1,
+		}
+	}
 
-		// 显示结果
-		if options.MergeOutput {
-			displayMergedResults(nodesBySubnet, results)
+	// 执行命令
+	var output, stderr string
+	startTime := time.Now()
+	run := func() {
+		if sessionManager.noPTY {
+			output, stderr, err = session.ExecuteCommandNoPTY(command, timeout)
 		} else {
-			displayResults(nodesBySubnet, results)
+			output, err = session.ExecuteCommand(command, timeout)
 		}
 	}
+	run()
 
-	return nil
-}
-
-// executeCommandOnNodes 在所有节点上执行命令
-func executeCommandOnNodes(sessionManager *SessionManager, nodes []model.Node, command string, timeout time.Duration) map[string]ExecResult {
-	results := make(map[string]ExecResult)
-	var wg sync.WaitGroup
-	var mutex sync.Mutex
+	// 命令没有发送到节点时，在重建的会话上重试一次
+	if errors.Is(err, errSessionLost) {
+		if rebuilt, rebuildErr := sessionManager.GetOrCreateSession(node); rebuildErr == nil {
+			session = rebuilt
+			run()
+		}
+	}
 
-	for _, node := range nodes {
-		wg.Add(1)
-		go func(node model.Node) {
-			defer wg.Done()
-
-			// 获取节点会话
-			session, err := sessionManager.GetOrCreateSession(node)
-			if err != nil {
-				mutex.Lock()
-				results[node.IP] = ExecResult{
-					Node:    node,
-					Output:  "",
-					Error:   fmt.Errorf("获取会话失败: %v", err),
-					Success: false,
-				}
-				mutex.Unlock()
-				return
-			}
+	result := ExecResult{
+		Node:     node,
+		Output:   output,
+		Stderr:   stderr,
+		Hostname: session.Hostname(),
+		Duration: time.Since(startTime),
+	}
+	applyExitStatus(&result, err)
+	// 命令没有正常结束时工作目录未知
+	if !sessionManager.noPTY && result.ExitCode >= 0 {
+		result.Cwd = session.Cwd()
+	}
+	return result
+}
 
-			// 执行命令
-			output, err := session.ExecuteCommand(command, timeout)
+// RecordResults 将各节点的执行结果按IP顺序写入会话记录
+// 命令的退出码取IP顺序中第一个失败节点的退出码，全部成功时为 0
+func RecordResults(recorder *Recorder, results map[string]ExecResult, duration time.Duration) {
+	ips := make([]string, 0, len(results))
+	for ip := range results {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool { return compareIP(ips[i], ips[j]) < 0 })
+
+	cmdExitCode := 0
+	for _, ip := range ips {
+		result := results[ip]
+		recorder.RecordOutput(result)
+		if cmdExitCode == 0 {
+			cmdExitCode = result.ExitCode
+		}
+	}
+	recorder.FinishCommand(cmdExitCode, duration)
+}
 
-			mutex.Lock()
-			results[node.IP] = ExecResult{
-				Node:    node,
-				Output:  output,
-				Error:   err,
-				Success: err == nil,
-			}
-			mutex.Unlock()
-		}(node)
+// CurrentUserName 返回本地当前用户名
+func CurrentUserName() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
 	}
+	return os.Getenv("USER")
+}
 
-	wg.Wait()
-	return results
+// DisplayResults 按子网分组显示执行结果，merge 为 true 时合并相同输出
+func DisplayResults(nodes []model.Node, results map[string]ExecResult, merge bool) {
+	nodesBySubnet := groupNodesBySubnet(nodes)
+	if merge {
+		displayMergedResults(nodesBySubnet, results, nil, SortIP)
+	} else {
+		displayResults(nodesBySubnet, results, SortIP)
+	}
 }
 
 // displayConnectedNodes 显示当前连接的节点
 func displayConnectedNodes(nodesBySubnet map[string][]model.Node) {
 	color.Cyan("当前连接的节点:")
-	for subnet, nodes := range nodesBySubnet {
+	for _, subnet := range sortedSubnets(nodesBySubnet) {
 		color.Yellow("%s:", subnet)
-		for _, node := range nodes {
+		for _, node := range nodesBySubnet[subnet] {
 			fmt.Printf("  - %s (用户: %s, 端口: %d)\n", node.IP, node.User, node.Port)
 		}
 	}
 	fmt.Println()
 }
 
-// displayResults 显示命令执行结果
-func displayResults(nodesBySubnet map[string][]model.Node, results map[string]ExecResult) {
-	for subnet, nodes := range nodesBySubnet {
+// displayResults 显示命令执行结果，子网按数值顺序排列，子网内按 sortBy 排序
+func displayResults(nodesBySubnet map[string][]model.Node, results map[string]ExecResult, sortBy string) {
+	for _, subnet := range sortedSubnets(nodesBySubnet) {
 		fmt.Print("\n")
 		color.New(color.FgHiCyan).Printf("%s:\n", subnet)
 
-		for _, node := range nodes {
+		for _, node := range sortNodes(nodesBySubnet[subnet], results, sortBy) {
 			result, ok := results[node.IP]
 			if !ok {
 				continue
 			}
 
 			if !result.Success {
-				errorDesc := result.Error.Error()
-				if strings.Contains(errorDesc, "命令退出码:") {
-					// 命令执行了但返回非零退出码
-					exitCode := strings.TrimPrefix(errorDesc, "命令退出码: ")
-					color.New(color.FgYellow).Printf("[%s] 退出码 %s\n", node.IP, exitCode)
-					if result.Output != "" {
-						fmt.Printf("%s\n", result.Output)
-					}
-				} else if strings.Contains(errorDesc, "command not found") || strings.Contains(errorDesc, "not found") {
-					color.New(color.FgRed).Printf("[%s] 命令未找到\n", node.IP)
-					if result.Output != "" {
-						fmt.Printf("    %s\n", result.Output)
-					}
-				} else if strings.Contains(errorDesc, "permission denied") {
-					color.New(color.FgRed).Printf("[%s] 权限拒绝\n", node.IP)
-					if result.Output != "" {
-						fmt.Printf("    %s\n", result.Output)
-					}
-				} else if strings.Contains(errorDesc, "connection refused") {
-					color.New(color.FgRed).Printf("[%s] 连接被拒绝\n", node.IP)
-				} else if strings.Contains(errorDesc, "命令已完成但处理超时") {
-					// 命令已完成但提取输出超时
-					color.New(color.FgYellow).Printf("[%s] 命令已完成但返回处理超时\n", node.IP)
-					if result.Output != "" {
-						fmt.Printf("%s\n", result.Output)
-					}
-				} else if strings.Contains(errorDesc, "timeout") || strings.Contains(errorDesc, "超时") {
-					// 命令执行真正超时
-					color.New(color.FgRed).Printf("[%s] 命令执行超时\n", node.IP)
-					if result.Output != "" {
-						fmt.Printf("%s\n", result.Output)
-					}
-				} else {
-					color.New(color.FgRed).Printf("[%s] 错误: %s\n", node.IP, errorDesc)
-					if result.Output != "" {
-						fmt.Printf("    %s\n", result.Output)
-					}
+				label, textColor := failureLabel(result)
+				color.New(textColor).Printf("[%s] %s\n", node.IP, label)
+				if result.Output != "" {
+					fmt.Printf("%s\n", result.Output)
 				}
 			} else {
 				// 命令执行成功
@@ -353,11 +310,42 @@ func displayResults(nodesBySubnet map[string][]model.Node, results map[string]Ex
 					fmt.Printf("%s\n", result.Output)
 				}
 			}
+
+			if result.Stderr != "" {
+				color.New(color.FgYellow).Printf("[%s] 标准错误:\n", node.IP)
+				fmt.Printf("%s\n", result.Stderr)
+			}
 		}
 	}
 	fmt.Print("\n")
 }
 
+// failureLabel 返回失败结果的描述和显示颜色
+// 命令已执行但返回非零退出码时用黄色，其余（超时、被信号终止、连接错误等）用红色
+func failureLabel(result ExecResult) (string, color.Attribute) {
+	switch {
+	case result.TimedOut:
+		return "命令执行超时", color.FgRed
+	case result.Signal != "":
+		return fmt.Sprintf("被信号 %s 终止 (退出码 %d)", result.Signal, result.ExitCode), color.FgRed
+	case result.ExitCode == 127:
+		return "命令未找到 (退出码 127)", color.FgRed
+	case result.ExitCode == 126:
+		return "命令无法执行 (退出码 126)", color.FgRed
+	case result.ExitCode > 0:
+		return fmt.Sprintf("退出码 %d", result.ExitCode), color.FgYellow
+	}
+
+	errorDesc := "未知错误"
+	if result.Error != nil {
+		errorDesc = result.Error.Error()
+	}
+	if strings.Contains(errorDesc, "connection refused") {
+		return "连接被拒绝", color.FgRed
+	}
+	return "错误: " + errorDesc, color.FgRed
+}
+
 // 辅助函数：检查字符串是否在切片中
 func contains(slice []string, str string) bool {
 	for _, item := range slice {
@@ -368,21 +356,28 @@ func contains(slice []string, str string) bool {
 	return false
 }
 
-// 按子网分组节点
+// 按子网分组节点，子网内按IP排序
 func groupNodesBySubnet(nodes []model.Node) map[string][]model.Node {
 	groups := make(map[string][]model.Node)
 
 	for _, node := range nodes {
-		// 提取IP的前三段作为分组依据
-		parts := strings.Split(node.IP, ".")
-		if len(parts) >= 3 {
-			groupKey := fmt.Sprintf("%s.%s.%s", parts[0], parts[1], parts[2])
-			groups[groupKey] = append(groups[groupKey], node)
-		} else {
-			// 如果IP格式不标准，使用原始IP作为键
-			groups[node.IP] = append(groups[node.IP], node)
-		}
+		groupKey := SubnetKey(node.IP)
+		groups[groupKey] = append(groups[groupKey], node)
 	}
 
+	for key, subnetNodes := range groups {
+		groups[key] = sortNodes(subnetNodes, nil, SortIP)
+	}
 	return groups
 }
+
+// SubnetKey 返回IP所属的子网分组键
+func SubnetKey(ip string) string {
+	// 提取IP的前三段作为分组依据
+	parts := strings.Split(ip, ".")
+	if len(parts) >= 3 {
+		return fmt.Sprintf("%s.%s.%s", parts[0], parts[1], parts[2])
+	}
+	// 如果IP格式不标准，使用原始IP作为键
+	return ip
+}
@@ -0,0 +1,95 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// ErrTimeout 表示命令在超时时间内没有结束
+var ErrTimeout = errors.New("命令执行超时")
+
+// ExitError 表示远程命令以非零状态结束
+type ExitError struct {
+	Code   int    // 退出码，被信号终止时为 128+信号值
+	Signal string // 终止进程的信号名，如 SIGKILL，正常退出时为空
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal != "" {
+		return fmt.Sprintf("命令被信号 %s 终止", e.Signal)
+	}
+	return fmt.Sprintf("命令退出码: %d", e.Code)
+}
+
+// signalNames 信号值 -> 信号名，只包含 Linux 上各架构一致的常见信号
+var signalNames = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+}
+
+// exitErrorFromCode 根据 shell 返回的退出码构造错误
+// shell 中被信号终止的进程退出码为 128+信号值，据此还原信号名
+func exitErrorFromCode(code int) error {
+	if code == 0 {
+		return nil
+	}
+	exitErr := &ExitError{Code: code}
+	if code > 128 {
+		exitErr.Signal = signalNames[code-128]
+	}
+	return exitErr
+}
+
+// exitErrorFromSignal 根据 SSH exit-signal 中的信号名（不带 SIG 前缀）构造错误
+func exitErrorFromSignal(name string) error {
+	signal := "SIG" + name
+	for num, known := range signalNames {
+		if known == signal {
+			return &ExitError{Code: 128 + num, Signal: signal}
+		}
+	}
+	return &ExitError{Code: -1, Signal: signal}
+}
+
+// ExitCodeError 根据 shell 返回的退出码构造错误，退出码为 0 时返回 nil
+func ExitCodeError(code int) error {
+	return exitErrorFromCode(code)
+}
+
+// NewExecResult 根据输出和执行错误构造节点的执行结果，用于显示不是由会话执行的命令的结果
+func NewExecResult(node model.Node, output string, err error) ExecResult {
+	result := ExecResult{Node: node, Output: output}
+	applyExitStatus(&result, err)
+	return result
+}
+
+// applyExitStatus 根据执行错误填充结果中的退出状态
+func applyExitStatus(result *ExecResult, err error) {
+	result.Error = err
+	result.Success = err == nil
+	if err == nil {
+		result.ExitCode = 0
+		return
+	}
+
+	result.ExitCode = -1
+	var exitErr *ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.Code
+		result.Signal = exitErr.Signal
+	case errors.Is(err, ErrTimeout):
+		result.TimedOut = true
+	}
+}
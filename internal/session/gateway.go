@@ -0,0 +1,409 @@
+package session
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/agent"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+)
+
+// 网关返回结果的额外等待时间，覆盖代理连接节点和传输结果的开销
+const gatewayGracePeriod = 30 * time.Second
+
+// GatewayNode 是子网的网关节点
+type GatewayNode struct {
+	model.Node
+	SendCredentials bool // 是否把目标节点的密码和私钥发给网关代理
+}
+
+// LoadGateways 加载组配置的网关，返回 子网 -> 网关节点
+func LoadGateways(groupName string) (map[string]GatewayNode, error) {
+	gateways, err := crud.ListGateways(groupName)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]GatewayNode, len(gateways))
+	for _, gw := range gateways {
+		node, err := crud.GetNode(gw.NodeID)
+		if err != nil {
+			return nil, fmt.Errorf("子网 %s 的网关无效: %v", gw.Subnet, err)
+		}
+		result[gw.Subnet] = GatewayNode{Node: node, SendCredentials: gw.SendCredentials}
+	}
+	return result, nil
+}
+
+// splitByGateway 将节点分为经网关执行的和直接连接的两部分
+// 网关节点本身总是直接连接
+func splitByGateway(nodes []model.Node, gateways map[string]GatewayNode) (map[string][]model.Node, []model.Node) {
+	viaGateway := make(map[string][]model.Node)
+	var direct []model.Node
+
+	for _, node := range nodes {
+		gw, ok := gateways[SubnetKey(node.IP)]
+		if !ok || gw.IP == node.IP {
+			direct = append(direct, node)
+			continue
+		}
+		viaGateway[gw.ID] = append(viaGateway[gw.ID], node)
+	}
+	return viaGateway, direct
+}
+
+// gatewayConn 获取网关代理连接，首次使用时上传并启动代理
+// 不向网关发送凭据时转发本地 SSH agent
+func (sm *SessionManager) gatewayConn(gw GatewayNode) (*agent.Conn, error) {
+	sm.mu.Lock()
+	conn, ok := sm.agents[gw.ID]
+	sm.mu.Unlock()
+	if ok {
+		return conn, nil
+	}
+
+	ns, err := sm.GetOrCreateSession(gw.Node)
+	if err != nil {
+		return nil, err
+	}
+
+	remotePath, err := agent.Ship(ns.Client())
+	if err != nil {
+		return nil, err
+	}
+	conn, err = agent.Start(ns.Client(), remotePath, !gw.SendCredentials)
+	if err != nil {
+		return nil, err
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if existing, ok := sm.agents[gw.ID]; ok {
+		conn.Close()
+		return existing, nil
+	}
+	sm.agents[gw.ID] = conn
+	return conn, nil
+}
+
+// dropGatewayConn 丢弃失效的网关代理连接
+func (sm *SessionManager) dropGatewayConn(gw model.Node) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if conn, ok := sm.agents[gw.ID]; ok {
+		conn.Close()
+		delete(sm.agents, gw.ID)
+	}
+}
+
+// connectGateways 预先启动所有网关代理，返回启动失败的网关及原因
+func connectGateways(sm *SessionManager, gateways map[string]GatewayNode) map[string]error {
+	failed := make(map[string]error)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	for _, gw := range gateways {
+		wg.Add(1)
+		go func(gw GatewayNode) {
+			defer wg.Done()
+			if _, err := sm.gatewayConn(gw); err != nil {
+				mutex.Lock()
+				failed[gw.ID] = err
+				mutex.Unlock()
+			}
+		}(gw)
+	}
+	wg.Wait()
+	return failed
+}
+
+// executeCommandViaGateways 经网关执行命令
+// 配置了网关的子网由网关就近执行，其余节点直接连接
+// 请求发给网关之前失败时该子网退回直接连接；请求发出后网关出错，未返回结果的节点报告错误而不重新执行
+func executeCommandViaGateways(sm *SessionManager, nodes []model.Node, command string, timeout time.Duration, gateways map[string]GatewayNode) map[string]ExecResult {
+	viaGateway, direct := splitByGateway(nodes, gateways)
+
+	gatewayByID := make(map[string]GatewayNode, len(gateways))
+	for _, gw := range gateways {
+		gatewayByID[gw.ID] = gw
+	}
+
+	results := make(map[string]ExecResult)
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+
+	for gwID, gwNodes := range viaGateway {
+		wg.Add(1)
+		go func(gw GatewayNode, gwNodes []model.Node) {
+			defer wg.Done()
+
+			// 只有请求确定没有发给网关时才直接连接，避免命令被执行两次
+			gwResults, err := executeOnGateway(sm, gw, gwNodes, command, timeout)
+			if err != nil {
+				color.Yellow("网关 %s 不可用，直接连接 %s: %v", gw.IP, CompressIPList(nodeIPs(gwNodes)), err)
+				gwResults = executeCommandOnNodes(sm, gwNodes, command, timeout)
+			}
+
+			mutex.Lock()
+			for ip, result := range gwResults {
+				results[ip] = result
+			}
+			mutex.Unlock()
+		}(gatewayByID[gwID], gwNodes)
+	}
+
+	if len(direct) > 0 {
+		directResults := executeCommandOnNodes(sm, direct, command, timeout)
+		mutex.Lock()
+		for ip, result := range directResults {
+			results[ip] = result
+		}
+		mutex.Unlock()
+	}
+
+	wg.Wait()
+	return results
+}
+
+// executeOnGateway 通过单个网关代理执行命令
+// 返回错误表示请求没有发给网关，命令没有执行；请求发出后的错误记录在未返回结果的节点上
+func executeOnGateway(sm *SessionManager, gw GatewayNode, nodes []model.Node, command string, timeout time.Duration) (map[string]ExecResult, error) {
+	conn, err := sm.gatewayConn(gw)
+	if err != nil {
+		return nil, err
+	}
+
+	req := agent.Request{
+		ID:      ip_util.GenerateShortID(),
+		Command: command,
+		Timeout: int(timeout / time.Second),
+
+		Fanout:   sm.Limiter().Workers(),
+		DialRate: sm.Limiter().DialRate(),
+		NoPTY:    sm.noPTY,
+	}
+	byIP := make(map[string]model.Node, len(nodes))
+	for _, node := range nodes {
+		agentNode, err := toAgentNode(node, gw.SendCredentials)
+		if err != nil {
+			return nil, err
+		}
+		req.Nodes = append(req.Nodes, agentNode)
+		byIP[node.IP] = node
+	}
+
+	// 代理无响应时关闭连接，避免一直阻塞
+	watchdog := time.AfterFunc(timeout+gatewayGracePeriod, func() { sm.dropGatewayConn(gw.Node) })
+	defer watchdog.Stop()
+
+	results := make(map[string]ExecResult, len(nodes))
+	err = conn.Exec(req, func(resp agent.Response) {
+		node, ok := byIP[resp.IP]
+		if !ok {
+			return
+		}
+		result := ExecResult{
+			Node:     node,
+			Output:   resp.Output,
+			Stderr:   resp.Stderr,
+			Success:  resp.Success,
+			ExitCode: resp.ExitCode,
+			Signal:   resp.Signal,
+			TimedOut: resp.TimedOut,
+			Hostname: resp.Hostname,
+			Duration: time.Duration(resp.Duration) * time.Millisecond,
+			Cwd:      resp.Cwd,
+		}
+		if resp.Error != "" {
+			result.Error = fmt.Errorf("%s", resp.Error)
+		}
+		results[resp.IP] = result
+	})
+	missing := fmt.Errorf("网关 %s 未返回结果", gw.IP)
+	if err != nil {
+		sm.dropGatewayConn(gw.Node)
+		if errors.Is(err, agent.ErrNotSent) {
+			return nil, err
+		}
+		// 已返回的结果有效，只对缺失的节点报错
+		missing = fmt.Errorf("网关 %s 未返回结果: %v", gw.IP, err)
+	}
+
+	for _, node := range nodes {
+		if _, ok := results[node.IP]; !ok {
+			results[node.IP] = ExecResult{
+				Node:     node,
+				Error:    missing,
+				ExitCode: -1,
+			}
+		}
+	}
+	return results, nil
+}
+
+// toAgentNode 将节点转换为代理请求中的节点
+// 只有 sendCredentials 为 true 时才传递密码和私钥内容，否则代理使用 SSH agent 和网关上的默认私钥
+func toAgentNode(node model.Node, sendCredentials bool) (agent.Node, error) {
+	agentNode := agent.Node{
+		IP:   node.IP,
+		Port: node.Port,
+		User: node.User,
+	}
+	if !sendCredentials {
+		return agentNode, nil
+	}
+	agentNode.Password = node.Password
+	if node.KeyPath != "" {
+		key, err := os.ReadFile(node.KeyPath)
+		if err != nil {
+			return agentNode, fmt.Errorf("读取节点 %s 的私钥失败: %v", node.IP, err)
+		}
+		agentNode.Key = string(key)
+	}
+	return agentNode, nil
+}
+
+// nodeIPs 返回节点的IP列表
+func nodeIPs(nodes []model.Node) []string {
+	ips := make([]string, len(nodes))
+	for i, node := range nodes {
+		ips[i] = node.IP
+	}
+	return ips
+}
+
+// ServeAgent 以网关代理模式运行
+// 从 r 按行读取请求，在本机就近连接目标节点执行，每个节点完成后立即把结果写入 w
+// 会话在多条请求之间保持，因此目标节点上的工作目录等状态与直接连接时一致
+func ServeAgent(r io.Reader, w io.Writer) error {
+	sm := NewSessionManager()
+	defer sm.CloseAll()
+
+	keys := newKeyFiles()
+	defer keys.cleanup()
+
+	var encMu sync.Mutex
+	enc := json.NewEncoder(w)
+	send := func(resp agent.Response) {
+		encMu.Lock()
+		defer encMu.Unlock()
+		enc.Encode(resp)
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var req agent.Request
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			return fmt.Errorf("解析请求失败: %v", err)
+		}
+
+		var nodes []model.Node
+		for _, agentNode := range req.Nodes {
+			node, err := keys.toNode(agentNode)
+			if err != nil {
+				send(agent.Response{ID: req.ID, IP: agentNode.IP, Error: err.Error(), ExitCode: -1})
+				continue
+			}
+			nodes = append(nodes, node)
+		}
+
+		// 请求之间串行处理，可以安全地按请求更新限制
+		sm.SetLimiter(fanout.New(req.Fanout, req.DialRate))
+		sm.SetNoPTY(req.NoPTY)
+		sm.Limiter().Run(len(nodes), func(i int) {
+			result := executeCommandOnNode(sm, nodes[i], req.Command, time.Duration(req.Timeout)*time.Second)
+			resp := agent.Response{
+				ID:       req.ID,
+				IP:       nodes[i].IP,
+				Output:   result.Output,
+				Stderr:   result.Stderr,
+				Success:  result.Success,
+				ExitCode: result.ExitCode,
+				Signal:   result.Signal,
+				TimedOut: result.TimedOut,
+				Hostname: result.Hostname,
+				Duration: result.Duration.Milliseconds(),
+				Cwd:      result.Cwd,
+			}
+			if result.Error != nil {
+				resp.Error = result.Error.Error()
+			}
+			send(resp)
+		})
+
+		send(agent.Response{ID: req.ID, Done: true})
+	}
+
+	return scanner.Err()
+}
+
+// keyFiles 管理代理模式下写入本地的临时私钥文件
+type keyFiles struct {
+	dir   string
+	paths map[string]string // 私钥摘要 -> 文件路径
+}
+
+func newKeyFiles() *keyFiles {
+	return &keyFiles{paths: make(map[string]string)}
+}
+
+// toNode 将代理请求中的节点转换为本地节点，私钥写入临时文件
+// 请求中没有凭据时使用 SSH agent 和本机用户的默认私钥认证
+func (k *keyFiles) toNode(agentNode agent.Node) (model.Node, error) {
+	node := model.Node{
+		ID:       agentNode.IP,
+		IP:       agentNode.IP,
+		Port:     agentNode.Port,
+		User:     agentNode.User,
+		Password: agentNode.Password,
+	}
+	if agentNode.Password == "" && agentNode.Key == "" {
+		node.AuthType = model.AuthAgent
+		return node, nil
+	}
+	if agentNode.Key == "" {
+		return node, nil
+	}
+
+	sum := sha256.Sum256([]byte(agentNode.Key))
+	digest := hex.EncodeToString(sum[:])
+	if path, ok := k.paths[digest]; ok {
+		node.KeyPath = path
+		return node, nil
+	}
+
+	if k.dir == "" {
+		dir, err := os.MkdirTemp("", "cluster-manager-agent-")
+		if err != nil {
+			return node, err
+		}
+		k.dir = dir
+	}
+	path := fmt.Sprintf("%s/%s", k.dir, digest[:16])
+	if err := os.WriteFile(path, []byte(agentNode.Key), 0600); err != nil {
+		return node, err
+	}
+	k.paths[digest] = path
+	node.KeyPath = path
+	return node, nil
+}
+
+// cleanup 删除临时私钥文件
+func (k *keyFiles) cleanup() {
+	if k.dir != "" {
+		os.RemoveAll(k.dir)
+	}
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+)
+
+// 本地后处理标记
+const (
+	localPipeMarker     = "| @local "
+	localRedirectMarker = "> @local "
+)
+
+// localDirective 是从输入中拆出的远程命令和本地后处理
+type localDirective struct {
+	remote  string // 在节点上执行的命令
+	filter  string // 每个节点的输出经过的本地过滤命令，为空时不过滤
+	saveDir string // 按IP保存输出的本地目录，为空时直接显示
+}
+
+// parseLocalDirective 拆分 `cmd | @local filter > @local dir/` 形式的输入，两种后处理都是可选的
+func parseLocalDirective(line string) localDirective {
+	d := localDirective{remote: line}
+	if i := strings.LastIndex(d.remote, localRedirectMarker); i >= 0 {
+		d.saveDir = strings.TrimSpace(d.remote[i+len(localRedirectMarker):])
+		d.remote = strings.TrimSpace(d.remote[:i])
+	}
+	if i := strings.LastIndex(d.remote, localPipeMarker); i >= 0 {
+		d.filter = strings.TrimSpace(d.remote[i+len(localPipeMarker):])
+		d.remote = strings.TrimSpace(d.remote[:i])
+	}
+	return d
+}
+
+// runLocalCommand 在本机执行命令，输入输出直接连接终端
+func runLocalCommand(command string) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		color.Red("本地命令执行失败: %v", err)
+	}
+}
+
+// applyLocalFilter 将每个节点的标准输出经本地命令过滤，相同的输出只过滤一次
+// 过滤命令以 0 或 1 退出都视为正常（如 grep 没有匹配），其他情况提示一次错误
+func applyLocalFilter(results map[string]ExecResult, filter string) map[string]ExecResult {
+	filtered := make(map[string]ExecResult, len(results))
+	cache := make(map[string]string)
+	reported := false
+
+	for ip, result := range results {
+		if result.Output == "" && !result.Success {
+			filtered[ip] = result
+			continue
+		}
+
+		output, ok := cache[result.Output]
+		if !ok {
+			var err error
+			output, err = runFilter(filter, result.Output)
+			if err != nil && !reported {
+				color.Red("本地过滤命令失败: %v", err)
+				reported = true
+			}
+			cache[result.Output] = output
+		}
+		result.Output = output
+		filtered[ip] = result
+	}
+	return filtered
+}
+
+// runFilter 以 input 为标准输入执行本地过滤命令
+func runFilter(filter, input string) (string, error) {
+	cmd := exec.Command("sh", "-c", filter)
+	cmd.Stdin = strings.NewReader(input + "\n")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		err = nil
+	}
+	if err != nil && stderr.Len() > 0 {
+		err = fmt.Errorf("%v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(stdout.String(), "\n"), err
+}
+
+// saveResults 将每个节点的输出保存到 dir/<IP>，单独捕获的标准错误保存到 dir/<IP>.stderr
+func saveResults(dir string, nodes []model.Node, results map[string]ExecResult) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建目录失败: %v", err)
+	}
+
+	var saved, failed []string
+	for _, node := range nodes {
+		result, ok := results[node.IP]
+		if !ok {
+			continue
+		}
+		if !result.Success {
+			failed = append(failed, node.IP)
+		}
+
+		path := filepath.Join(dir, node.IP)
+		if err := os.WriteFile(path, []byte(result.Output+"\n"), 0644); err != nil {
+			return fmt.Errorf("保存 %s 的输出失败: %v", node.IP, err)
+		}
+		if result.Stderr != "" {
+			if err := os.WriteFile(path+".stderr", []byte(result.Stderr+"\n"), 0644); err != nil {
+				return fmt.Errorf("保存 %s 的标准错误失败: %v", node.IP, err)
+			}
+		}
+		saved = append(saved, node.IP)
+	}
+
+	color.Green("已将 %d 个节点的输出保存到 %s", len(saved), dir)
+	if len(failed) > 0 {
+		color.Yellow("其中 %d 个节点执行失败: %s", len(failed), CompressIPList(failed))
+	}
+	return nil
+}
@@ -3,47 +3,91 @@ package session
 import (
 	"fmt"
 	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/agent"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
 	"zhaowanpeng/cluster-manager/model"
 )
 
 // SessionManager 管理所有活跃的会话
 type SessionManager struct {
-	sessions map[string]*NodeSession
-	mu       sync.Mutex
+	sessions map[string]*sessionSlot
+	agents   map[string]*agent.Conn // 网关节点ID -> 网关代理连接
+	limiter  *fanout.Limiter        // 所有并发路径共享的并发和建连速率限制
+	noPTY    bool                   // 不分配伪终端，分别捕获标准输出和标准错误
+	mu       sync.Mutex             // 保护 sessions 和 agents 两个映射，不在建连期间持有
+}
+
+// sessionSlot 保存一个节点的会话，建连和重建只锁定该节点
+type sessionSlot struct {
+	mu      sync.Mutex
+	session *NodeSession
 }
 
 // NewSessionManager 创建新的会话管理器
 func NewSessionManager() *SessionManager {
 	return &SessionManager{
-		sessions: make(map[string]*NodeSession),
+		sessions: make(map[string]*sessionSlot),
+		agents:   make(map[string]*agent.Conn),
+		limiter:  fanout.Default(),
 	}
 }
 
+// SetLimiter 设置并发和建连速率限制，nil 表示不限制
+func (sm *SessionManager) SetLimiter(limiter *fanout.Limiter) {
+	sm.limiter = limiter
+}
+
+// Limiter 返回会话管理器使用的限制器
+func (sm *SessionManager) Limiter() *fanout.Limiter {
+	return sm.limiter
+}
+
+// SetNoPTY 设置是否以不分配伪终端的方式执行命令
+func (sm *SessionManager) SetNoPTY(noPTY bool) {
+	sm.noPTY = noPTY
+}
+
 // GetOrCreateSession 获取或创建节点会话
+// 已有会话仍然可用时直接返回，不产生网络往返；失效的会话透明重建，并恢复工作目录和环境变量
+// 不同节点的建连互不阻塞
 func (sm *SessionManager) GetOrCreateSession(node model.Node) (*NodeSession, error) {
+	key := fmt.Sprintf("%s:%d:%s", node.IP, node.Port, node.User)
+
 	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	slot, exists := sm.sessions[key]
+	if !exists {
+		slot = &sessionSlot{}
+		sm.sessions[key] = slot
+	}
+	sm.mu.Unlock()
 
-	key := fmt.Sprintf("%s:%d:%s", node.IP, node.Port, node.User)
-	if session, exists := sm.sessions[key]; exists {
-		// 验证会话是否仍然有效
-		err := session.Ping()
-		if err == nil {
-			return session, nil
+	slot.mu.Lock()
+	defer slot.mu.Unlock()
+
+	var state shellState
+	if slot.session != nil {
+		if slot.session.Alive() {
+			return slot.session, nil
 		}
 
-		// 会话失效，需要关闭并重建
-		session.Close()
-		delete(sm.sessions, key)
+		// 连接或shell已断开，关闭后重建
+		state = slot.session.state()
+		slot.session.Close()
+		slot.session = nil
 	}
 
-	// 创建新会话
-	session, err := NewNodeSession(node)
+	// 创建新会话，需要建立新连接时受建连速率限制
+	if !sshconn.Shared.Connected(node) {
+		sm.limiter.WaitDial()
+	}
+	session, err := newNodeSession(node, state)
 	if err != nil {
 		return nil, err
 	}
 
-	sm.sessions[key] = session
+	slot.session = session
 	return session, nil
 }
 
@@ -52,8 +96,24 @@ func (sm *SessionManager) CloseAll() {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
-	for key, session := range sm.sessions {
-		session.Close()
+	// 先关闭网关代理，再关闭其所在的SSH连接
+	for key, conn := range sm.agents {
+		conn.Close()
+		delete(sm.agents, key)
+	}
+
+	for key, slot := range sm.sessions {
+		slot.mu.Lock()
+		if slot.session != nil {
+			slot.session.Close()
+			slot.session = nil
+		}
+		slot.mu.Unlock()
 		delete(sm.sessions, key)
 	}
 }
+
+// RunCommand 在给定节点上并发执行命令，返回以IP为键的执行结果
+func (sm *SessionManager) RunCommand(nodes []model.Node, command string, timeout time.Duration) map[string]ExecResult {
+	return executeCommandOnNodes(sm, nodes, command, timeout)
+}
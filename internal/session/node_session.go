@@ -2,38 +2,88 @@ package session
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"zhaowanpeng/cluster-manager/internal/sshconn"
 	"zhaowanpeng/cluster-manager/model"
 
 	"golang.org/x/crypto/ssh"
 )
 
+// 保活请求的发送间隔和等待响应的超时时间
+const (
+	keepaliveInterval = 15 * time.Second
+	keepaliveTimeout  = 10 * time.Second
+)
+
+// errSessionLost 表示命令没有发送到节点：连接或长会话的shell已断开，可以在重建的会话上重试
+var errSessionLost = errors.New("会话已断开")
+
+// errShellExited 表示命令执行过程中长会话的shell退出，命令可能已经部分执行
+var errShellExited = errors.New("会话的shell已退出")
+
 // NodeSession 表示与单个节点的长会话
 type NodeSession struct {
 	Node            model.Node
 	client          *ssh.Client
 	shellSession    *ssh.Session
-	stdin           *bytes.Buffer
-	stdout          *bytes.Buffer
-	stderr          *bytes.Buffer
-	environmentVars map[string]string
+	stdin           io.WriteCloser
+	stdout          *syncBuffer
+	stderr          *syncBuffer
+	environmentVars map[string]string // 用户导出的环境变量，值为 shell 中的写法（可能带引号）
+	hostname        string            // 建立会话时获取的远程主机名，用于合并输出时屏蔽
+
+	cwd     string            // 最近一条命令结束时的工作目录
+	baseEnv map[string]string // 新建shell时已有的导出变量，用于区分用户导出的变量
+	envSum  string            // 导出变量的校验和，变化时重新读取
+
+	alive     atomic.Bool   // 连接是否可用，由保活和连接监视维护
+	shellMu   sync.Mutex    // 保护 shellDone
+	shellDone chan struct{} // 当前shell退出时关闭
+	stop      chan struct{} // 会话关闭时关闭，停止保活
+	closeOnce sync.Once
+}
+
+// syncBuffer 是并发安全的输出缓冲区
+// SSH 库在后台 goroutine 中写入输出，读取方在另一个 goroutine 中轮询
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
 }
 
 // NewNodeSession 创建新的节点会话
 func NewNodeSession(node model.Node) (*NodeSession, error) {
-	// 创建SSH客户端连接
-	config := &ssh.ClientConfig{
-		User: node.User,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(node.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         5 * time.Second,
-	}
+	return newNodeSession(node, shellState{})
+}
 
-	client, err := ssh.Dial("tcp", fmt.Sprintf("%s:%d", node.IP, node.Port), config)
+// newNodeSession 创建新的节点会话，并恢复之前会话的工作目录和环境变量
+func newNodeSession(node model.Node, state shellState) (*NodeSession, error) {
+	// 从共享连接池获取SSH连接，配置了跳板机时经跳板机链转发
+	client, err := sshconn.Shared.Client(node, 5*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("连接到节点 %s 失败: %v", node.IP, err)
 	}
@@ -43,14 +93,20 @@ func NewNodeSession(node model.Node) (*NodeSession, error) {
 		Node:            node,
 		client:          client,
 		environmentVars: make(map[string]string),
+		cwd:             state.cwd,
+		stop:            make(chan struct{}),
+	}
+	for name, value := range state.env {
+		session.environmentVars[name] = value
 	}
 
 	// 初始化shell会话
 	if err := session.initShellSession(); err != nil {
-		client.Close()
 		return nil, err
 	}
 
+	session.alive.Store(true)
+	go session.keepalive()
 	return session, nil
 }
 
@@ -68,11 +124,16 @@ func (ns *NodeSession) initShellSession() error {
 	}
 
 	// 设置I/O
-	ns.stdin = &bytes.Buffer{}
-	ns.stdout = &bytes.Buffer{}
-	ns.stderr = &bytes.Buffer{}
+	// 标准输入必须使用管道：若直接赋值缓冲区，SSH 库读到空缓冲区的 EOF 后会关闭远程 shell 的输入
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return fmt.Errorf("创建输入管道失败: %v", err)
+	}
+	ns.stdin = stdin
+	ns.stdout = &syncBuffer{}
+	ns.stderr = &syncBuffer{}
 
-	session.Stdin = ns.stdin
 	session.Stdout = ns.stdout
 	session.Stderr = ns.stderr
 
@@ -93,6 +154,14 @@ func (ns *NodeSession) initShellSession() error {
 	}
 
 	ns.shellSession = session
+	done := make(chan struct{})
+	go func() {
+		session.Wait()
+		close(done)
+	}()
+	ns.shellMu.Lock()
+	ns.shellDone = done
+	ns.shellMu.Unlock()
 
 	// 等待shell准备就绪
 	time.Sleep(500 * time.Millisecond)
@@ -107,16 +176,34 @@ func (ns *NodeSession) initShellSession() error {
 		"export PS1='> '",         // 设置简单提示符
 		"stty -echo",              // 禁用终端回显
 		"unalias ls 2>/dev/null",  // 移除ls别名（如果有）
+		"unset HISTFILE",          // 不写入远程历史，避免密码等敏感参数落盘
+		envFuncDef,                // 列出需要跟踪的导出变量
 	}
 
 	for _, cmd := range setupCmds {
-		ns.stdin.WriteString(cmd + "\n")
+		if _, err := io.WriteString(ns.stdin, cmd+"\n"); err != nil {
+			session.Close()
+			ns.shellSession = nil
+			return fmt.Errorf("初始化shell环境失败: %v", err)
+		}
 	}
 
 	// 再次等待这些设置命令执行完成
 	time.Sleep(300 * time.Millisecond)
 	ns.stdout.Reset()
 
+	// 记录初始导出变量，并恢复之前会话的工作目录和环境变量
+	if err := ns.restoreState(); err != nil {
+		session.Close()
+		ns.shellSession = nil
+		return err
+	}
+
+	// 记录主机名，获取失败不影响会话使用
+	if hostname, err := ns.ExecuteCommand("hostname", 3*time.Second); err == nil {
+		ns.hostname = strings.TrimSpace(hostname)
+	}
+
 	return nil
 }
 
@@ -132,21 +219,28 @@ func (ns *NodeSession) ExecuteCommand(command string, timeout time.Duration) (st
 	// 使用管道分隔符实现更可靠的命令执行和返回
 	// 这将确保命令输出与状态的分离
 	execID := fmt.Sprintf("CMD_END_%d", time.Now().UnixNano())
-	execCmd := fmt.Sprintf("{ %s; } 2>&1; echo -e \"\\n%s:$?:CMD_END\"\n", command, execID)
+	// 结束标记中带上退出码、导出变量的校验和及当前工作目录
+	execCmd := fmt.Sprintf("{ %s; } 2>&1; echo -e \"\\n%s:$?:$(%s | cksum):$PWD:CMD_END\"\n", command, execID, envFuncName)
+
+	// shell已退出时命令不会被执行
+	if ns.shellExited() {
+		return "", errSessionLost
+	}
 
 	// 清空输出缓冲区
 	ns.stdout.Reset()
 	ns.stderr.Reset()
 
 	// 写入命令
-	_, err := ns.stdin.WriteString(execCmd)
+	_, err := io.WriteString(ns.stdin, execCmd)
 	if err != nil {
-		return "", fmt.Errorf("写入命令失败: %v", err)
+		ns.alive.Store(false)
+		return "", fmt.Errorf("%w: 写入命令失败: %v", errSessionLost, err)
 	}
 
 	// 等待命令执行完成
 	doneChan := make(chan struct{})
-	var output string
+	var output, envSum, cwd string
 	var cmdErr error
 
 	go func() {
@@ -160,22 +254,37 @@ func (ns *NodeSession) ExecuteCommand(command string, timeout time.Duration) (st
 			currentOutput := ns.stdout.String()
 			endMarker := fmt.Sprintf("%s:", execID)
 
-			// 检查输出中是否包含我们的结束标记
-			if idx := strings.Index(currentOutput, endMarker); idx >= 0 {
+			// 检查输出中是否包含完整的结束标记
+			idx := strings.Index(currentOutput, endMarker)
+			end := -1
+			if idx >= 0 {
+				end = strings.Index(currentOutput[idx:], ":CMD_END")
+			}
+			if end >= 0 {
 				// 提取命令的真实输出
 				commandOutput := currentOutput[:idx]
 
-				// 提取退出码
-				markerWithCode := currentOutput[idx:]
-				parts := strings.Split(markerWithCode, ":")
-				if len(parts) >= 3 {
-					exitCode := parts[1]
-					if exitCode != "0" {
-						cmdErr = fmt.Errorf("命令退出码: %s", exitCode)
-					}
+				// 提取退出码、导出变量校验和和工作目录，工作目录中可能含有冒号
+				parts := strings.SplitN(currentOutput[idx+len(endMarker):idx+end], ":", 3)
+				if exitCode, err := strconv.Atoi(strings.TrimSpace(parts[0])); err == nil {
+					cmdErr = exitErrorFromCode(exitCode)
+				} else {
+					cmdErr = fmt.Errorf("无法解析退出码: %q", parts[0])
+				}
+				if len(parts) == 3 {
+					envSum, cwd = parts[1], parts[2]
 				}
 
-				output = strings.TrimSpace(commandOutput)
+				// 伪终端输出使用 \r\n 换行，统一为 \n 便于比较和合并
+				output = strings.TrimSpace(strings.ReplaceAll(commandOutput, "\r\n", "\n"))
+				close(doneChan)
+				return
+			}
+
+			// shell已退出，不会再有结束标记
+			if ns.shellExited() {
+				output = strings.TrimSpace(strings.ReplaceAll(currentOutput, "\r\n", "\n"))
+				cmdErr = errShellExited
 				close(doneChan)
 				return
 			}
@@ -190,6 +299,7 @@ func (ns *NodeSession) ExecuteCommand(command string, timeout time.Duration) (st
 	// 等待命令完成或超时
 	select {
 	case <-doneChan:
+		ns.trackState(envSum, cwd)
 		return output, cmdErr
 	case <-time.After(timeout):
 		// 获取当前已收集的输出
@@ -200,31 +310,69 @@ func (ns *NodeSession) ExecuteCommand(command string, timeout time.Duration) (st
 			currentOutput := ns.stdout.String()
 			return strings.TrimSpace(currentOutput), fmt.Errorf("命令已完成但处理超时")
 		}
-		return strings.TrimSpace(partialOutput), fmt.Errorf("命令执行超时")
+		return strings.TrimSpace(partialOutput), ErrTimeout
 	}
 }
 
+// ExecuteCommandNoPTY 在独立的 exec 通道中执行命令，不分配伪终端
+// 标准输出和标准错误分别返回；每条命令在新的 shell 中执行，不保留工作目录，
+// 在长会话中导出（包括通过 SetEnvironmentVariable 设置）的环境变量会在命令前重新导出
+func (ns *NodeSession) ExecuteCommandNoPTY(command string, timeout time.Duration) (string, string, error) {
+	session, err := ns.client.NewSession()
+	if err != nil {
+		return "", "", fmt.Errorf("%w: 创建SSH会话失败: %v", errSessionLost, err)
+	}
+	defer session.Close()
+
+	stdout := &syncBuffer{}
+	stderr := &syncBuffer{}
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	var exports strings.Builder
+	for name, value := range ns.environmentVars {
+		fmt.Fprintf(&exports, "export %s=%s; ", name, value)
+	}
+
+	if err := session.Start(exports.String() + command); err != nil {
+		return "", "", fmt.Errorf("启动命令失败: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-time.After(timeout):
+		session.Signal(ssh.SIGKILL)
+		return strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()), ErrTimeout
+	}
+
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		if exitErr.Signal() != "" {
+			err = exitErrorFromSignal(exitErr.Signal())
+		} else {
+			err = exitErrorFromCode(exitErr.ExitStatus())
+		}
+	} else if err != nil {
+		err = fmt.Errorf("命令执行失败: %v", err)
+	}
+	return strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String()), err
+}
+
 // 在会话中执行shell文件内容
 func (ns *NodeSession) ExecuteShellFile(shellContent string) (string, error) {
 	cmd := fmt.Sprintf("cat <<EOF | bash\n%s\nEOF", shellContent)
 	return ns.ExecuteCommand(cmd, 10*time.Second)
 }
 
-// 恢复会话
-// func (ns *NodeSession) RestoreSession() error {
-
-// }
-
 // SetEnvironmentVariable 设置环境变量
-// 注意：环境变量只会在当前会话中有效，不会跨命令保留
+// 与在会话中执行 export 相同，变量会被跟踪并在会话重建时恢复
 func (ns *NodeSession) SetEnvironmentVariable(name, value string) error {
 	cmd := fmt.Sprintf("export %s=%s", name, value)
 	_, err := ns.ExecuteCommand(cmd, 3*time.Second)
-
-	// 保存到本地记录，作为参考
-	if err == nil {
-		ns.environmentVars[name] = value
-	}
 	return err
 }
 
@@ -234,23 +382,90 @@ func (ns *NodeSession) GetEnvironmentVariable(name string) (string, error) {
 	return ns.ExecuteCommand(cmd, 3*time.Second)
 }
 
-// Close 关闭会话
-func (ns *NodeSession) Close() {
-	if ns.shellSession != nil {
-		ns.shellSession.Close()
+// Hostname 返回远程主机名，未能获取时为空
+func (ns *NodeSession) Hostname() string {
+	return ns.hostname
+}
+
+// Client 返回会话底层的SSH连接，可用于在同一连接上开启其他通道
+func (ns *NodeSession) Client() *ssh.Client {
+	return ns.client
+}
+
+// Alive 返回连接和长会话的shell是否仍然可用，不产生网络往返
+func (ns *NodeSession) Alive() bool {
+	return ns.alive.Load() && !ns.shellExited()
+}
+
+// shellExited 返回当前shell是否已退出
+func (ns *NodeSession) shellExited() bool {
+	ns.shellMu.Lock()
+	done := ns.shellDone
+	ns.shellMu.Unlock()
+	if done == nil {
+		return false
 	}
-	if ns.client != nil {
-		ns.client.Close()
+	select {
+	case <-done:
+		return true
+	default:
+		return false
 	}
 }
 
-// Ping 检查会话是否仍然有效
-func (ns *NodeSession) Ping() error {
-	if ns.client == nil || ns.shellSession == nil {
-		return fmt.Errorf("会话未初始化")
+// keepalive 定期发送 keepalive@openssh.com 请求，连接断开或请求超时时标记会话失效
+// 服务端不认识该请求时会回复失败，同样说明连接可用
+func (ns *NodeSession) keepalive() {
+	closed := make(chan struct{})
+	go func() {
+		ns.client.Wait()
+		ns.alive.Store(false)
+		close(closed)
+	}()
+
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ns.stop:
+			return
+		case <-closed:
+			return
+		case <-ticker.C:
+		}
+
+		replied := make(chan error, 1)
+		go func() {
+			_, _, err := ns.client.SendRequest("keepalive@openssh.com", true, nil)
+			replied <- err
+		}()
+
+		select {
+		case err := <-replied:
+			if err == nil {
+				continue
+			}
+		case <-time.After(keepaliveTimeout):
+		case <-ns.stop:
+			return
+		}
+
+		// 关闭连接使阻塞在该连接上的读写尽快返回
+		ns.alive.Store(false)
+		ns.client.Close()
+		return
 	}
+}
 
-	// 尝试执行一个简单命令来测试会话
-	_, err := ns.ExecuteCommand("echo ping", 2*time.Second)
-	return err
+// Close 关闭会话的shell，SSH连接由共享连接池管理，继续供其他通道使用
+func (ns *NodeSession) Close() {
+	ns.alive.Store(false)
+	ns.closeOnce.Do(func() {
+		if ns.stop != nil {
+			close(ns.stop)
+		}
+	})
+	if ns.shellSession != nil {
+		ns.shellSession.Close()
+	}
 }
@@ -0,0 +1,155 @@
+package session
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/config"
+
+	"github.com/fatih/color"
+)
+
+// 内置归一化规则名
+const (
+	maskIP        = "ip"
+	maskHostname  = "hostname"
+	maskUUID      = "uuid"
+	maskTimestamp = "timestamp"
+	maskNumber    = "number"
+)
+
+var (
+	uuidPattern = regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`)
+	// ISO 8601、date 命令默认格式和单独的时分秒
+	timestampPattern = regexp.MustCompile(
+		`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?` +
+			`|(?:Mon|Tue|Wed|Thu|Fri|Sat|Sun) (?:Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) +\d{1,2} \d{2}:\d{2}:\d{2}(?: [A-Z]{2,5})? \d{4}` +
+			`|\b\d{2}:\d{2}:\d{2}(?:\.\d+)?\b`)
+	numberPattern = regexp.MustCompile(`\d+`)
+)
+
+// maskColor 高亮代表输出中被屏蔽的部分
+var maskColor = color.New(color.FgHiMagenta, color.Underline)
+
+// normalizeRule 是一条归一化规则，pattern 为 nil 的规则按节点动态生成
+type normalizeRule struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// Normalizer 在合并分组前屏蔽输出中因节点而异的部分
+// 规则按顺序生效，多条规则匹配同一段文本时靠前的规则优先
+type Normalizer struct {
+	maskIP       bool
+	maskHostname bool
+	rules        []normalizeRule
+}
+
+// NewNormalizer 根据配置创建归一化器：节点自身IP、主机名，用户规则，UUID、时间戳，最后是数字
+func NewNormalizer(cfg config.MergeConfig) (*Normalizer, error) {
+	disabled := make(map[string]bool)
+	for _, name := range cfg.DisableBuiltins {
+		disabled[strings.ToLower(name)] = true
+	}
+
+	n := &Normalizer{
+		maskIP:       !disabled[maskIP],
+		maskHostname: !disabled[maskHostname],
+	}
+
+	for _, rule := range cfg.Rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("归一化规则 %s 无效: %v", rule.Name, err)
+		}
+		name := rule.Name
+		if name == "" {
+			name = "custom"
+		}
+		n.rules = append(n.rules, normalizeRule{name: name, pattern: re})
+	}
+
+	builtins := []normalizeRule{
+		{maskUUID, uuidPattern},
+		{maskTimestamp, timestampPattern},
+		{maskNumber, numberPattern},
+	}
+	for _, rule := range builtins {
+		if !disabled[rule.name] {
+			n.rules = append(n.rules, rule)
+		}
+	}
+	return n, nil
+}
+
+// maskSpan 是文本中被某条规则屏蔽的一段
+type maskSpan struct {
+	start, end int
+	name       string
+}
+
+// spans 找出文本中所有需要屏蔽的片段，按位置排序且互不重叠
+func (n *Normalizer) spans(text, ip, hostname string) []maskSpan {
+	rules := make([]normalizeRule, 0, len(n.rules)+2)
+	if n.maskIP && ip != "" {
+		rules = append(rules, normalizeRule{maskIP, regexp.MustCompile(`\b` + regexp.QuoteMeta(ip) + `\b`)})
+	}
+	if n.maskHostname && hostname != "" {
+		rules = append(rules, normalizeRule{maskHostname, regexp.MustCompile(`\b` + regexp.QuoteMeta(hostname) + `\b`)})
+	}
+	rules = append(rules, n.rules...)
+
+	var spans []maskSpan
+	taken := func(start, end int) bool {
+		for _, s := range spans {
+			if start < s.end && s.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	for _, rule := range rules {
+		for _, loc := range rule.pattern.FindAllStringIndex(text, -1) {
+			if loc[0] == loc[1] || taken(loc[0], loc[1]) {
+				continue
+			}
+			spans = append(spans, maskSpan{loc[0], loc[1], rule.name})
+		}
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	return spans
+}
+
+// Normalize 返回屏蔽后的文本，被屏蔽的部分替换为 <规则名>
+func (n *Normalizer) Normalize(text, ip, hostname string) string {
+	return n.rewrite(text, ip, hostname, func(span maskSpan, _ string) string {
+		return "<" + span.name + ">"
+	})
+}
+
+// Highlight 返回高亮了被屏蔽部分的原文
+func (n *Normalizer) Highlight(text, ip, hostname string) string {
+	return n.rewrite(text, ip, hostname, func(_ maskSpan, original string) string {
+		return maskColor.Sprint(original)
+	})
+}
+
+// rewrite 用 replace 的结果替换文本中每个被屏蔽的片段
+func (n *Normalizer) rewrite(text, ip, hostname string, replace func(span maskSpan, original string) string) string {
+	spans := n.spans(text, ip, hostname)
+	if len(spans) == 0 {
+		return text
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, span := range spans {
+		b.WriteString(text[last:span.start])
+		b.WriteString(replace(span, text[span.start:span.end]))
+		last = span.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
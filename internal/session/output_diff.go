@@ -0,0 +1,164 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+)
+
+// diffContext 差异显示中每个差异块前后的上下文行数
+const diffContext = 3
+
+// diffBucket 是输出相同（归一化时为归一化后相同）的一组节点
+type diffBucket struct {
+	output  string   // 第一个节点的输出，归一化时标出被屏蔽的部分，用于显示
+	compare string   // 与基准比较的文本，归一化时为归一化后的文本
+	label   string   // 失败描述，成功时为空
+	ips     []string // 组内全部节点
+	list    string   // 压缩后的节点列表，用于显示
+}
+
+// displayDiffResults 以多数节点的输出为基准显示执行结果，nodes 应已按 sortBy 排序，其余分组按 sortBy 排列（见 groupOrder）
+// 基准输出只显示一次，其余每组输出显示与基准的 unified diff；未返回任何输出的失败节点按失败原因列出
+// 设置了归一化时比较归一化后的文本，只显示归一化无法消除的差异
+func displayDiffResults(nodes []model.Node, results map[string]ExecResult, normalizer *Normalizer, sortBy string) {
+	var buckets []*diffBucket
+	byKey := make(map[string]*diffBucket)
+	errorGroups := make(map[string][]string)
+	errorClasses := make(map[string]int)
+
+	for _, node := range nodes {
+		result, ok := results[node.IP]
+		if !ok {
+			continue
+		}
+
+		label := ""
+		if !result.Success {
+			label, _ = failureLabel(result)
+			// 未执行到命令的节点没有可比较的输出
+			if result.Output == "" && result.ExitCode < 0 {
+				errorGroups[label] = append(errorGroups[label], node.IP)
+				errorClasses[label] = failureClass(result)
+				continue
+			}
+		}
+
+		compare, sample := mergeKey(diffText(result), result, normalizer)
+		key := label + "\x00" + compare
+		bucket, ok := byKey[key]
+		if !ok {
+			bucket = &diffBucket{output: sample, compare: compare, label: label}
+			byKey[key] = bucket
+			buckets = append(buckets, bucket)
+		}
+		bucket.ips = append(bucket.ips, node.IP)
+	}
+
+	fmt.Print("\n")
+	for _, label := range sortedErrorLabels(errorGroups, errorClasses) {
+		ips := errorGroups[label]
+		list, err := ip_util.CompressIPList(ips)
+		if err != nil {
+			list = strings.Join(ips, ",")
+		}
+		color.New(color.FgRed).Printf("[%s] %s (%d 个节点)\n", list, label, len(ips))
+	}
+
+	if len(buckets) == 0 {
+		fmt.Print("\n")
+		return
+	}
+
+	// 节点最多的一组作为基准，数量相同时取先出现的一组
+	baseline := buckets[0]
+	for _, bucket := range buckets[1:] {
+		if len(bucket.ips) > len(baseline.ips) {
+			baseline = bucket
+		}
+	}
+
+	// 其余分组按排序方式显示
+	before := groupOrder(nodes, sortBy)
+	sort.SliceStable(buckets, func(i, j int) bool { return before(buckets[i].ips, buckets[j].ips) })
+
+	total := 0
+	for _, bucket := range buckets {
+		total += len(bucket.ips)
+		list, err := ip_util.CompressIPList(bucket.ips)
+		if err != nil {
+			list = strings.Join(bucket.ips, ",")
+		}
+		bucket.list = list
+	}
+
+	header := fmt.Sprintf("[%s] 基准输出 (%d/%d 个节点)", baseline.list, len(baseline.ips), total)
+	if baseline.label != "" {
+		header += ", " + baseline.label
+	}
+	color.New(color.FgGreen).Println(header + ":")
+	if baseline.output != "" {
+		fmt.Println(baseline.output)
+	}
+
+	for _, bucket := range buckets {
+		if bucket == baseline {
+			continue
+		}
+
+		fmt.Println()
+		header := fmt.Sprintf("[%s] 与基准不同 (%d 个节点)", bucket.list, len(bucket.ips))
+		if bucket.label != "" {
+			header += ", " + bucket.label
+		}
+		color.New(color.FgYellow).Println(header + ":")
+
+		diff, ok := utils.UnifiedDiff("基准", bucket.list, baseline.compare, bucket.compare, diffContext)
+		if !ok {
+			color.Yellow("输出过大，无法比较，完整输出:")
+			fmt.Println(bucket.output)
+			continue
+		}
+		if diff == "" {
+			// 输出相同但退出状态不同
+			fmt.Println("(输出与基准相同)")
+			continue
+		}
+		printDiff(diff)
+	}
+	fmt.Print("\n")
+}
+
+// diffText 返回参与比较的文本，标准错误单独捕获时附在输出之后
+func diffText(result ExecResult) string {
+	if result.Stderr == "" {
+		return result.Output
+	}
+	if result.Output == "" {
+		return "[stderr]\n" + result.Stderr
+	}
+	return result.Output + "\n[stderr]\n" + result.Stderr
+}
+
+// printDiff 按行着色显示 unified diff
+func printDiff(diff string) {
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			color.New(color.Bold).Println(line)
+		case strings.HasPrefix(line, "@@"):
+			color.Cyan(line)
+		case strings.HasPrefix(line, "+"):
+			color.Green(line)
+		case strings.HasPrefix(line, "-"):
+			color.Red(line)
+		default:
+			fmt.Println(line)
+		}
+	}
+}
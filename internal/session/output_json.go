@@ -0,0 +1,66 @@
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// 结果显示格式
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// resultJSON 是单个节点执行结果的 JSON 表示
+type resultJSON struct {
+	IP       string `json:"ip"`
+	Success  bool   `json:"success"`
+	ExitCode int    `json:"exit_code"`
+	Signal   string `json:"signal,omitempty"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Duration int64  `json:"duration_ms"`
+	Cwd      string `json:"cwd,omitempty"`
+	Output   string `json:"output"`
+	Stderr   string `json:"stderr,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// commandJSON 是一条命令在所有节点上执行结果的 JSON 表示
+type commandJSON struct {
+	Command string       `json:"command"`
+	Results []resultJSON `json:"results"`
+}
+
+// DisplayResultsJSON 以 JSON 格式输出执行结果，每条命令输出一行，节点按给定顺序排列
+func DisplayResultsJSON(command string, nodes []model.Node, results map[string]ExecResult) error {
+	out := commandJSON{Command: command, Results: make([]resultJSON, 0, len(results))}
+	for _, node := range nodes {
+		result, ok := results[node.IP]
+		if !ok {
+			continue
+		}
+		item := resultJSON{
+			IP:       node.IP,
+			Success:  result.Success,
+			ExitCode: result.ExitCode,
+			Signal:   result.Signal,
+			TimedOut: result.TimedOut,
+			Duration: result.Duration.Milliseconds(),
+			Cwd:      result.Cwd,
+			Output:   result.Output,
+			Stderr:   result.Stderr,
+		}
+		if result.Error != nil {
+			item.Error = result.Error.Error()
+		}
+		out.Results = append(out.Results, item)
+	}
+
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
@@ -2,6 +2,7 @@ package session
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
 	"zhaowanpeng/cluster-manager/model"
@@ -9,16 +10,37 @@ import (
 	"github.com/fatih/color"
 )
 
+// outputGroup 是标准输出相同（归一化时为归一化后相同）的一组节点
+type outputGroup struct {
+	key      string                  // 分组键，归一化时为归一化后的输出
+	sample   string                  // 代表输出，归一化时高亮被屏蔽的部分
+	ips      []string                // 组内全部节点
+	byStderr map[string]*stderrGroup // 标准错误 -> 节点分组
+}
+
+// stderrGroup 是同一输出下标准错误相同的一组节点
+type stderrGroup struct {
+	key    string
+	sample string
+	ips    []string
+}
+
 // displayMergedResults 显示合并后的命令执行结果
-func displayMergedResults(nodesBySubnet map[string][]model.Node, results map[string]ExecResult) {
-	for subnet, nodes := range nodesBySubnet {
+// normalizer 不为 nil 时先屏蔽输出中因节点而异的部分再分组，每组显示第一个节点的输出作为代表
+// 子网按数值顺序排列；失败分组按失败类别排列，输出分组按 sortBy 排列（见 groupOrder）
+func displayMergedResults(nodesBySubnet map[string][]model.Node, results map[string]ExecResult, normalizer *Normalizer, sortBy string) {
+	for _, subnet := range sortedSubnets(nodesBySubnet) {
 		fmt.Print("\n")
 		color.New(color.FgHiCyan).Printf("%s:\n", subnet)
 
-		// 按输出内容分组
-		successOutputGroups := make(map[string][]string) // 输出 -> IP列表
-		errorGroups := make(map[string][]string)         // 错误信息 -> IP列表
-		errorOutputGroups := make(map[string][]string)   // 错误输出 -> IP列表
+		nodes := sortNodes(nodesBySubnet[subnet], results, sortBy)
+		before := groupOrder(nodes, sortBy)
+
+		// 按输出内容分组，同一输出下再按标准错误分组
+		successOutputGroups := make(map[string]*outputGroup) // 输出 -> 分组
+		errorGroups := make(map[string][]string)             // 失败描述 -> IP列表
+		errorClasses := make(map[string]int)                 // 失败描述 -> 失败类别
+		errorOutputGroups := make(map[string]*outputGroup)   // 错误输出 -> 分组
 
 		for _, node := range nodes {
 			result, ok := results[node.IP]
@@ -27,40 +49,152 @@ func displayMergedResults(nodesBySubnet map[string][]model.Node, results map[str
 			}
 
 			if !result.Success {
-				errorMsg := result.Error.Error()
-				errorGroups[errorMsg] = append(errorGroups[errorMsg], node.IP)
+				label, _ := failureLabel(result)
+				errorGroups[label] = append(errorGroups[label], node.IP)
+				errorClasses[label] = failureClass(result)
 
-				if result.Output != "" {
-					errorOutputGroups[result.Output] = append(errorOutputGroups[result.Output], node.IP)
+				if result.Output != "" || result.Stderr != "" {
+					addOutputGroup(errorOutputGroups, result, normalizer)
 				}
 			} else {
-				successOutputGroups[result.Output] = append(successOutputGroups[result.Output], node.IP)
+				addOutputGroup(successOutputGroups, result, normalizer)
 			}
 		}
 
 		// 显示错误
-		for errorMsg, ips := range errorGroups {
-			displayNodeGroup(ips, color.FgRed, "错误")
-			fmt.Printf("  %s\n", errorMsg)
+		for _, label := range sortedErrorLabels(errorGroups, errorClasses) {
+			displayNodeGroup(errorGroups[label], color.FgRed, label)
 		}
 
 		// 显示错误输出
-		for output, ips := range errorOutputGroups {
-			displayNodeGroup(ips, color.FgYellow, "错误输出")
-			fmt.Printf("  %s\n", output)
+		for _, group := range sortedOutputGroups(errorOutputGroups, before) {
+			displayNodeGroup(group.ips, color.FgYellow, "错误输出")
+			if group.sample != "" {
+				fmt.Printf("  %s\n", group.sample)
+			}
+			displayStderrGroups(group.byStderr)
 		}
 
 		// 显示成功输出
-		for output, ips := range successOutputGroups {
-			displayNodeGroup(ips, color.FgGreen, "")
-			if output != "" {
-				fmt.Printf("  %s\n", output)
+		for _, group := range sortedOutputGroups(successOutputGroups, before) {
+			displayNodeGroup(group.ips, color.FgGreen, "")
+			if group.sample != "" {
+				fmt.Printf("  %s\n", group.sample)
 			}
+			displayStderrGroups(group.byStderr)
 		}
 	}
 	fmt.Print("\n")
 }
 
+// addOutputGroup 将结果按 输出 -> 标准错误 加入分组
+func addOutputGroup(groups map[string]*outputGroup, result ExecResult, normalizer *Normalizer) {
+	key, sample := mergeKey(result.Output, result, normalizer)
+	group, ok := groups[key]
+	if !ok {
+		group = &outputGroup{key: key, sample: sample, byStderr: make(map[string]*stderrGroup)}
+		groups[key] = group
+	}
+	group.ips = append(group.ips, result.Node.IP)
+
+	key, sample = mergeKey(result.Stderr, result, normalizer)
+	stderr, ok := group.byStderr[key]
+	if !ok {
+		stderr = &stderrGroup{key: key, sample: sample}
+		group.byStderr[key] = stderr
+	}
+	stderr.ips = append(stderr.ips, result.Node.IP)
+}
+
+// mergeKey 返回用于分组的键和用于显示的代表文本
+func mergeKey(text string, result ExecResult, normalizer *Normalizer) (string, string) {
+	if normalizer == nil {
+		return text, text
+	}
+	return normalizer.Normalize(text, result.Node.IP, result.Hostname),
+		normalizer.Highlight(text, result.Node.IP, result.Hostname)
+}
+
+// sortedErrorLabels 返回排序后的失败描述：按失败类别，同类按节点数从多到少
+func sortedErrorLabels(errorGroups map[string][]string, classes map[string]int) []string {
+	labels := make([]string, 0, len(errorGroups))
+	for label := range errorGroups {
+		labels = append(labels, label)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		a, b := labels[i], labels[j]
+		if classes[a] != classes[b] {
+			return classes[a] < classes[b]
+		}
+		if len(errorGroups[a]) != len(errorGroups[b]) {
+			return len(errorGroups[a]) > len(errorGroups[b])
+		}
+		return a < b
+	})
+	return labels
+}
+
+// sortedOutputGroups 返回按节点数从多到少排列的输出分组
+func sortedOutputGroups(groups map[string]*outputGroup, before func(a, b []string) bool) []*outputGroup {
+	sorted := make([]*outputGroup, 0, len(groups))
+	for _, group := range groups {
+		sorted = append(sorted, group)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return before(sorted[i].ips, sorted[j].ips) })
+	return sorted
+}
+
+// groupBefore 分组排序规则：节点多的在前，节点数相同时按第一个IP排序
+func groupBefore(a, b []string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return compareIP(a[0], b[0]) < 0
+}
+
+// groupOrder 返回按 sortBy 排列分组的规则，nodes 应已按 sortBy 排序，分组内IP按 nodes 的顺序加入
+// 按IP排序时使用 groupBefore；按其他方式排序时，排在最前的节点所在的分组在前，如耗时最长的节点所在的分组
+func groupOrder(nodes []model.Node, sortBy string) func(a, b []string) bool {
+	if sortBy == "" || sortBy == SortIP {
+		return groupBefore
+	}
+	rank := make(map[string]int, len(nodes))
+	for i, node := range nodes {
+		rank[node.IP] = i
+	}
+	return func(a, b []string) bool { return rank[a[0]] < rank[b[0]] }
+}
+
+// displayStderrGroups 显示同一输出下的标准错误
+// 所有节点标准错误相同时直接显示，不同时按标准错误分组列出
+func displayStderrGroups(byStderr map[string]*stderrGroup) {
+	if len(byStderr) == 1 {
+		for _, group := range byStderr {
+			if group.sample != "" {
+				color.New(color.FgYellow).Println("  标准错误:")
+				fmt.Printf("  %s\n", group.sample)
+			}
+		}
+		return
+	}
+
+	groups := make([]*stderrGroup, 0, len(byStderr))
+	for _, group := range byStderr {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groupBefore(groups[i].ips, groups[j].ips) })
+
+	color.New(color.FgYellow).Println("  标准输出相同，标准错误不同:")
+	for _, group := range groups {
+		displayNodeGroup(group.ips, color.FgYellow, "标准错误")
+		if group.sample == "" {
+			fmt.Println("  (无)")
+		} else {
+			fmt.Printf("  %s\n", group.sample)
+		}
+	}
+}
+
 // displayNodeGroup 显示节点组
 func displayNodeGroup(ips []string, textColor color.Attribute, label string) {
 	c := color.New(textColor)
@@ -3,21 +3,24 @@ package session
 import (
 	"time"
 
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
 	"zhaowanpeng/cluster-manager/model"
 )
 
 // Recorder 用于记录会话
 type Recorder struct {
-	session     *model.Session
-	commands    []*model.Command
-	currentCmd  *model.Command
-	isRecording bool
+	session           *model.Session
+	commands          []*model.Command
+	currentCmd        *model.Command
+	isRecording       bool
+	compressThreshold int // 输出超过该字节数时压缩存储，0 表示不压缩
 }
 
 // NewRecorder 创建新的会话记录器
 func NewRecorder(name, description, user, groupName string) *Recorder {
 	session := &model.Session{
-		ID:          time.Now().Format("20060102150405"),
+		ID:          newRecordID(),
 		Name:        name,
 		Description: description,
 		StartTime:   time.Now(),
@@ -32,6 +35,22 @@ func NewRecorder(name, description, user, groupName string) *Recorder {
 	}
 }
 
+// newRecordID 生成记录ID
+// 时间前缀便于排序，随机后缀避免同一毫秒内多个节点的输出主键冲突
+func newRecordID() string {
+	return time.Now().Format("20060102150405.000") + "-" + ip_util.GenerateShortID()
+}
+
+// SessionID 返回记录的会话ID
+func (r *Recorder) SessionID() string {
+	return r.session.ID
+}
+
+// SetCompressThreshold 设置输出压缩阈值（字节），0 表示不压缩
+func (r *Recorder) SetCompressThreshold(threshold int) {
+	r.compressThreshold = threshold
+}
+
 // Start 开始记录会话
 func (r *Recorder) Start() error {
 	if r.isRecording {
@@ -54,7 +73,7 @@ func (r *Recorder) RecordCommand(cmdStr string) {
 	}
 
 	r.currentCmd = &model.Command{
-		ID:        time.Now().Format("20060102150405.000"),
+		ID:        newRecordID(),
 		SessionID: r.session.ID,
 		Command:   cmdStr,
 		ExecTime:  time.Now(),
@@ -65,18 +84,33 @@ func (r *Recorder) RecordCommand(cmdStr string) {
 	r.commands = append(r.commands, r.currentCmd)
 }
 
-// RecordOutput 记录命令输出
-func (r *Recorder) RecordOutput(nodeIP, output string, exitCode int) {
+// RecordOutput 记录命令在单个节点上的输出和退出状态
+func (r *Recorder) RecordOutput(result ExecResult) {
 	if !r.isRecording || r.currentCmd == nil {
 		return
 	}
 
 	cmdOutput := &model.CommandOutput{
-		ID:        time.Now().Format("20060102150405.000"),
+		ID:        newRecordID(),
 		CommandID: r.currentCmd.ID,
-		NodeIP:    nodeIP,
-		Output:    output,
-		ExitCode:  exitCode,
+		NodeIP:    result.Node.IP,
+		Output:    result.Output,
+		Stderr:    result.Stderr,
+		ExitCode:  result.ExitCode,
+		Signal:    result.Signal,
+		TimedOut:  result.TimedOut,
+	}
+	output, stderr := result.Output, result.Stderr
+
+	// 大输出压缩后存储，标准输出和标准错误一起压缩，压缩失败时保留原文
+	if r.compressThreshold > 0 && len(output)+len(stderr) >= r.compressThreshold {
+		compressedOut, errOut := utils.CompressText(output)
+		compressedErr, errErr := utils.CompressText(stderr)
+		if errOut == nil && errErr == nil {
+			cmdOutput.Output = compressedOut
+			cmdOutput.Stderr = compressedErr
+			cmdOutput.Compressed = true
+		}
 	}
 
 	// 保存命令输出到数据库
@@ -107,3 +141,19 @@ func (r *Recorder) Stop() {
 	model.DB.Save(r.session)
 	r.isRecording = false
 }
+
+// OutputText 返回输出的原文，自动解压压缩存储的内容
+func OutputText(output model.CommandOutput) (string, error) {
+	if !output.Compressed {
+		return output.Output, nil
+	}
+	return utils.DecompressText(output.Output)
+}
+
+// StderrText 返回标准错误的原文，自动解压压缩存储的内容
+func StderrText(output model.CommandOutput) (string, error) {
+	if !output.Compressed || output.Stderr == "" {
+		return output.Stderr, nil
+	}
+	return utils.DecompressText(output.Stderr)
+}
@@ -0,0 +1,672 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/config"
+	"zhaowanpeng/cluster-manager/internal/crud"
+	"zhaowanpeng/cluster-manager/internal/fanout"
+	"zhaowanpeng/cluster-manager/internal/utils/ip_util"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/chzyer/readline"
+	"github.com/fatih/color"
+)
+
+// 补全时加载的历史命令数
+const completionHistoryLimit = 500
+
+// 常见命令的快速超时时间，避免无效等待
+var fastCmds = map[string]bool{
+	"whoami": true, "hostname": true, "uptime": true,
+	"date": true, "pwd": true, "id": true, "echo": true,
+	"ls": true, "ps": true, "df": true, "free": true,
+	"uname": true, "which": true, "type": true,
+}
+
+// metaCommands 会话内的元命令及说明
+var metaCommands = []struct {
+	name, usage, help string
+}{
+	{":exclude", ":exclude <范围>", "从当前节点中排除节点"},
+	{":add", ":add <范围>", "添加节点，未保存的节点使用 -a 指定的连接信息"},
+	{":only", ":only <范围>", "只在给定范围内的已知节点上执行"},
+	{":merge", ":merge on|off", "开启或关闭合并输出"},
+	{":timeout", ":timeout <秒>", "设置命令超时时间，如 30 或 2m"},
+	{":group", ":group <组名>", "切换到其他组"},
+	{":save-failed", ":save-failed <组名>", "将上一条命令失败的节点保存为临时组"},
+	{":nodes", ":nodes", "显示当前节点"},
+	{":broadcast", ":broadcast [范围]", "进入广播终端模式，键盘输入同时发送到各节点，可运行 vim、top 等全屏程序"},
+	{":help", ":help", "显示元命令帮助"},
+}
+
+// repl 是组执行的交互式会话
+// 保存当前组、活动节点集合和可以在会话中修改的选项
+type repl struct {
+	options    ExecOptions
+	sm         *SessionManager
+	group      model.Group
+	gateways   map[string]GatewayNode
+	pool       []model.Node // 会话中已知的全部节点：组内节点和 :add 添加的节点
+	nodes      []model.Node // 当前执行命令的节点
+	normalizer *Normalizer
+	recorder   *Recorder
+	rl         *readline.Instance
+	completer  *replCompleter
+
+	lastCommand string
+	lastResults map[string]ExecResult
+}
+
+// newRepl 创建交互式会话，连接到 options.GroupName 指定的组
+func newRepl(options ExecOptions) (*repl, error) {
+	r := &repl{
+		options:   options,
+		sm:        NewSessionManager(),
+		completer: &replCompleter{},
+	}
+	r.sm.SetNoPTY(options.NoPTY)
+
+	if err := r.loadNormalizer(); err != nil {
+		r.sm.CloseAll()
+		return nil, err
+	}
+	if err := r.switchGroup(options.GroupName, options.AddNodes, options.ExcludeNodes); err != nil {
+		r.sm.CloseAll()
+		return nil, err
+	}
+
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       r.prompt(),
+		HistoryFile:  historyPath(r.group.Name),
+		AutoComplete: r.completer,
+	})
+	if err != nil {
+		r.close()
+		return nil, fmt.Errorf("创建交互式会话失败: %v", err)
+	}
+	r.rl = rl
+	return r, nil
+}
+
+// close 结束记录并关闭所有连接
+func (r *repl) close() {
+	if r.rl != nil {
+		r.rl.Close()
+	}
+	if r.recorder != nil {
+		r.recorder.Stop()
+	}
+	r.sm.CloseAll()
+}
+
+// prompt 返回当前组的提示符
+func (r *repl) prompt() string {
+	return color.GreenString(r.group.Name) + " > "
+}
+
+// historyPath 返回组的命令历史文件路径，无法创建目录时不保存历史
+func historyPath(groupName string) string {
+	appDir, err := model.AppDir()
+	if err != nil {
+		return ""
+	}
+	dir := filepath.Join(appDir, "history")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return ""
+	}
+	name := strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(groupName)
+	return filepath.Join(dir, name)
+}
+
+// loadNormalizer 合并或差异显示时按配置加载归一化规则
+func (r *repl) loadNormalizer() error {
+	r.normalizer = nil
+	if !r.options.MergeOutput && !r.options.DiffOutput {
+		return nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if r.options.Normalize || cfg.Merge.Normalize {
+		r.normalizer, err = NewNormalizer(cfg.Merge)
+	}
+	return err
+}
+
+// switchGroup 切换到指定组并连接其节点，addRange 和 excludeRange 在组节点的基础上增减
+// 切换失败时保持原来的组和节点不变
+func (r *repl) switchGroup(name, addRange, excludeRange string) error {
+	group, pool, nodes, err := resolveGroupNodes(r.options, name, addRange, excludeRange)
+	if err != nil {
+		return err
+	}
+
+	// 启动网关代理，网关后面的节点由网关连接，不需要预连接
+	var gateways map[string]GatewayNode
+	if r.options.UseGateways {
+		gateways, err = LoadGateways(name)
+		if err != nil {
+			return fmt.Errorf("加载网关失败: %v", err)
+		}
+		if len(gateways) > 0 {
+			color.Yellow("正在启动 %d 个网关代理...", len(gateways))
+			failedGateways := connectGateways(r.sm, gateways)
+			for subnet, gw := range gateways {
+				if err, failed := failedGateways[gw.ID]; failed {
+					color.Red("网关 %s 启动失败，子网 %s 改为直接连接: %v", gw.IP, subnet, err)
+					delete(gateways, subnet)
+				}
+			}
+		}
+	}
+
+	// 并发和建连速率按 命令行 > 组配置 > 默认值 限制，连接失败时恢复原来的限制
+	previous := r.sm.Limiter()
+	r.sm.SetLimiter(fanout.ForGroup(group, r.options.Fanout, r.options.DialRate))
+
+	nodes = r.connect(nodes, gateways)
+	if len(nodes) == 0 {
+		r.sm.SetLimiter(previous)
+		return fmt.Errorf("所有连接都失败了")
+	}
+
+	r.group = group
+	r.pool = pool
+	r.nodes = nodes
+	r.gateways = gateways
+	r.lastCommand, r.lastResults = "", nil
+
+	color.Green("已连接到组 '%s' 的 %d 个节点", group.Name, len(nodes))
+	displayConnectedNodes(groupNodesBySubnet(nodes))
+
+	// 每个组单独记录会话，记录失败不影响命令执行
+	if r.recorder != nil {
+		r.recorder.Stop()
+	}
+	r.recorder = NewRecorder(group.Name, "", CurrentUserName(), group.Name)
+	r.recorder.SetCompressThreshold(r.options.CompressThreshold)
+	if err := r.recorder.Start(); err != nil {
+		color.Yellow("会话记录启动失败: %v", err)
+	}
+
+	// 命令历史和补全按组区分
+	if commands, err := crud.RecentCommands(group.Name, completionHistoryLimit); err == nil {
+		r.completer.setCommands(commands)
+	}
+	if r.rl != nil {
+		r.rl.SetHistoryPath(historyPath(group.Name))
+		r.rl.SetPrompt(r.prompt())
+	}
+	return nil
+}
+
+// newNode 为未保存的IP创建节点，使用命令行指定的连接信息
+func (r *repl) newNode(ip, groupName string) model.Node {
+	return newAdHocNode(r.options, ip, groupName)
+}
+
+// connect 预连接节点，返回连接成功（或由网关负责连接）的节点
+func (r *repl) connect(nodes []model.Node, gateways map[string]GatewayNode) []model.Node {
+	_, direct := splitByGateway(nodes, gateways)
+
+	color.Yellow("正在建立SSH连接到 %d 个节点...", len(direct))
+	var mutex sync.Mutex
+	var failedNodes []string
+
+	r.sm.Limiter().Run(len(direct), func(i int) {
+		node := direct[i]
+		if _, err := r.sm.GetOrCreateSession(node); err != nil {
+			mutex.Lock()
+			failedNodes = append(failedNodes, node.IP)
+			color.Red("连接节点 %s 失败: %v", node.IP, err)
+			mutex.Unlock()
+		}
+	})
+
+	if len(failedNodes) == 0 {
+		return nodes
+	}
+	return filterNodes(nodes, failedNodes, false)
+}
+
+// run 运行交互式循环，直到输入 exit 或读取结束
+func (r *repl) run() {
+	for {
+		line, err := r.rl.Readline()
+		// 如果输入为空，则退出
+		if err != nil {
+			break
+		}
+
+		// 去掉命令前后空白
+		command := strings.TrimSpace(line)
+		if command == "" {
+			continue
+		}
+
+		// 处理特殊命令
+		if command == "exit" || command == "quit" || command == "ctrl+q" {
+			color.Green("退出会话")
+			break
+		}
+
+		if command == "nodes" {
+			// 显示当前连接的节点
+			displayConnectedNodes(groupNodesBySubnet(r.nodes))
+			continue
+		}
+
+		if strings.HasPrefix(command, "!") {
+			runLocalCommand(strings.TrimSpace(command[1:]))
+			continue
+		}
+
+		if strings.HasPrefix(command, ":") {
+			if err := r.handleMeta(command); err != nil {
+				color.Red("%v", err)
+			}
+			continue
+		}
+
+		r.execute(command)
+	}
+}
+
+// execute 在当前节点上执行命令，记录并显示结果
+// 输入中的 `| @local` 和 `> @local` 部分在本机处理，只有之前的部分发送到节点
+func (r *repl) execute(line string) {
+	directive := parseLocalDirective(line)
+	command := directive.remote
+	if command == "" {
+		color.Red("缺少要在节点上执行的命令")
+		return
+	}
+	r.completer.addCommand(line)
+
+	// 对于一些基本命令使用更短的超时
+	cmdTimeout := r.options.Timeout
+	if cmdParts := strings.Fields(command); len(cmdParts) > 0 && fastCmds[cmdParts[0]] && cmdTimeout > 5*time.Second {
+		cmdTimeout = 5 * time.Second // 快速命令使用5秒超时
+	}
+
+	// 执行命令并收集结果
+	startTime := time.Now()
+	r.recorder.RecordCommand(command)
+	runBatch := func(batch []model.Node, command string) map[string]ExecResult {
+		if len(r.gateways) > 0 {
+			return executeCommandViaGateways(r.sm, batch, command, cmdTimeout, r.gateways)
+		}
+		return executeCommandOnNodes(r.sm, batch, command, cmdTimeout)
+	}
+	var results map[string]ExecResult
+	if r.options.Strategy.Enabled() {
+		showCanary := func(batch []model.Node, results map[string]ExecResult) {
+			displayMergedResults(groupNodesBySubnet(batch), results, r.normalizer, r.options.SortBy)
+		}
+		results = executeWithStrategy(r.nodes, command, r.options.Strategy, runBatch, confirmFunc(r.rl, r.prompt()), showCanary)
+	} else {
+		results = runBatch(r.nodes, command)
+	}
+	RecordResults(r.recorder, results, time.Since(startTime))
+	r.lastCommand, r.lastResults = command, results
+
+	if directive.filter != "" {
+		results = applyLocalFilter(results, directive.filter)
+	}
+	if directive.saveDir != "" {
+		if err := saveResults(directive.saveDir, sortNodes(r.nodes, results, SortIP), results); err != nil {
+			color.Red("%v", err)
+		}
+		return
+	}
+	r.display(command, results)
+	if r.options.OutputFormat != FormatJSON {
+		displayCwdDrift(sortNodes(r.nodes, results, SortIP), results)
+	}
+}
+
+// display 按当前选项显示结果
+func (r *repl) display(command string, results map[string]ExecResult) {
+	switch {
+	case r.options.OutputFormat == FormatJSON:
+		if err := DisplayResultsJSON(command, sortNodes(r.nodes, results, r.options.SortBy), results); err != nil {
+			color.Red("输出JSON失败: %v", err)
+		}
+	case r.options.DiffOutput:
+		displayDiffResults(sortNodes(r.nodes, results, r.options.SortBy), results, r.normalizer, r.options.SortBy)
+	case r.options.MergeOutput:
+		displayMergedResults(groupNodesBySubnet(r.nodes), results, r.normalizer, r.options.SortBy)
+	default:
+		displayResults(groupNodesBySubnet(r.nodes), results, r.options.SortBy)
+	}
+}
+
+// handleMeta 处理以冒号开头的元命令
+func (r *repl) handleMeta(line string) error {
+	fields := strings.Fields(line)
+	name, args := fields[0], fields[1:]
+	arg := strings.Join(args, " ")
+
+	needArg := func() error {
+		if arg == "" {
+			return fmt.Errorf("用法: %s", metaUsage(name))
+		}
+		return nil
+	}
+
+	switch name {
+	case ":exclude":
+		if err := needArg(); err != nil {
+			return err
+		}
+		ips, err := ip_util.ParseIPRange(arg)
+		if err != nil {
+			return fmt.Errorf("解析节点范围失败: %v", err)
+		}
+		remaining := filterNodes(r.nodes, ips, false)
+		if len(remaining) == 0 {
+			return fmt.Errorf("不能排除所有节点")
+		}
+		color.Green("已排除 %d 个节点，当前 %d 个节点", len(r.nodes)-len(remaining), len(remaining))
+		r.nodes = remaining
+
+	case ":add":
+		if err := needArg(); err != nil {
+			return err
+		}
+		ips, err := ip_util.ParseIPRange(arg)
+		if err != nil {
+			return fmt.Errorf("解析节点范围失败: %v", err)
+		}
+		var added []model.Node
+		for _, ip := range ips {
+			if findNode(r.nodes, ip) >= 0 {
+				continue
+			}
+			added = append(added, r.resolveNode(ip))
+		}
+		count := r.addActive(added)
+		color.Green("已添加 %d 个节点，当前 %d 个节点", count, len(r.nodes))
+
+	case ":only":
+		if err := needArg(); err != nil {
+			return err
+		}
+		ips, err := ip_util.ParseIPRange(arg)
+		if err != nil {
+			return fmt.Errorf("解析节点范围失败: %v", err)
+		}
+		selected := filterNodes(r.pool, ips, true)
+		if len(selected) == 0 {
+			return fmt.Errorf("范围内没有已知节点，可使用 :add 添加")
+		}
+		r.nodes = nil
+		r.addActive(selected)
+		color.Green("只在 %d 个节点上执行", len(r.nodes))
+
+	case ":merge":
+		switch arg {
+		case "on":
+			r.options.MergeOutput = true
+			r.options.DiffOutput = false
+		case "off":
+			r.options.MergeOutput = false
+		default:
+			return fmt.Errorf("用法: %s", metaUsage(name))
+		}
+		if err := r.loadNormalizer(); err != nil {
+			return err
+		}
+		color.Green("合并输出: %s", arg)
+
+	case ":timeout":
+		if err := needArg(); err != nil {
+			return err
+		}
+		timeout, err := parseTimeout(arg)
+		if err != nil {
+			return err
+		}
+		r.options.Timeout = timeout
+		color.Green("命令执行超时设置为 %s", timeout)
+
+	case ":group":
+		if err := needArg(); err != nil {
+			return err
+		}
+		return r.switchGroup(arg, "", "")
+
+	case ":save-failed":
+		if err := needArg(); err != nil {
+			return err
+		}
+		return r.saveFailed(arg)
+
+	case ":nodes":
+		displayConnectedNodes(groupNodesBySubnet(r.nodes))
+
+	case ":broadcast":
+		return r.broadcast(arg)
+
+	case ":help":
+		for _, meta := range metaCommands {
+			fmt.Printf("  %-22s %s\n", meta.usage, meta.help)
+		}
+		fmt.Printf("  %-22s %s\n", "!<命令>", "在本机执行命令")
+		fmt.Printf("  %-22s %s\n", "<命令> | @local <过滤>", "每个节点的输出经本地命令过滤后显示")
+		fmt.Printf("  %-22s %s\n", "<命令> > @local <目录>", "按IP将每个节点的输出保存到本地目录")
+
+	default:
+		return fmt.Errorf("未知的元命令 %s，输入 :help 查看帮助", name)
+	}
+	return nil
+}
+
+// metaUsage 返回元命令的用法
+func metaUsage(name string) string {
+	for _, meta := range metaCommands {
+		if meta.name == name {
+			return meta.usage
+		}
+	}
+	return name
+}
+
+// parseTimeout 解析超时时间，纯数字按秒计算
+func parseTimeout(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("无效的超时时间: %s", value)
+	}
+	return timeout, nil
+}
+
+// resolveNode 查找IP对应的节点：会话中已知的节点、其他组保存的节点，否则按命令行连接信息新建
+func (r *repl) resolveNode(ip string) model.Node {
+	if i := findNode(r.pool, ip); i >= 0 {
+		return r.pool[i]
+	}
+	if node, err := crud.ResolveNodeRef(ip); err == nil {
+		return node
+	}
+	return r.newNode(ip, r.group.Name)
+}
+
+// addActive 连接并加入活动节点，连接失败的节点不会加入
+func (r *repl) addActive(nodes []model.Node) int {
+	if len(nodes) == 0 {
+		return 0
+	}
+
+	connected := r.connect(nodes, r.gateways)
+	for _, node := range connected {
+		if findNode(r.pool, node.IP) < 0 {
+			r.pool = append(r.pool, node)
+		}
+		if findNode(r.nodes, node.IP) < 0 {
+			r.nodes = append(r.nodes, node)
+		}
+	}
+	return len(connected)
+}
+
+// broadcast 在当前节点（或其中给定范围内的节点）上进入广播终端模式
+// 经网关连接的节点没有本地SSH连接，不参与广播
+func (r *repl) broadcast(rangeArg string) error {
+	nodes := r.nodes
+	if rangeArg != "" {
+		ips, err := ip_util.ParseIPRange(rangeArg)
+		if err != nil {
+			return fmt.Errorf("解析节点范围失败: %v", err)
+		}
+		nodes = filterNodes(nodes, ips, true)
+	}
+
+	_, direct := splitByGateway(nodes, r.gateways)
+	if skipped := len(nodes) - len(direct); skipped > 0 {
+		color.Yellow("%d 个经网关连接的节点不参与广播", skipped)
+	}
+	if len(direct) == 0 {
+		return fmt.Errorf("没有可广播的节点")
+	}
+
+	if err := RunBroadcast(r.sm, sortNodes(direct, nil, SortIP)); err != nil {
+		return err
+	}
+	fmt.Print("\n")
+	color.Green("已退出广播模式")
+	return nil
+}
+
+// saveFailed 将上一条命令失败的节点保存为临时组
+func (r *repl) saveFailed(groupName string) error {
+	if r.lastResults == nil {
+		return fmt.Errorf("还没有执行过命令")
+	}
+
+	var failed []model.Node
+	for _, node := range r.nodes {
+		if result, ok := r.lastResults[node.IP]; ok && !result.Success {
+			failed = append(failed, node)
+		}
+	}
+	if len(failed) == 0 {
+		color.Green("上一条命令没有失败的节点")
+		return nil
+	}
+
+	desc := fmt.Sprintf("组 %s 执行 %q 失败的节点", r.group.Name, r.lastCommand)
+	if err := crud.AddGroup(groupName, desc, r.group.User, true); err != nil {
+		return err
+	}
+	count, err := crud.CopyNodesToGroup(groupName, failed)
+	if err != nil {
+		return err
+	}
+	color.Green("已将 %d 个失败节点保存到临时组 '%s'", count, groupName)
+	return nil
+}
+
+// findNode 返回IP在节点列表中的位置，不存在时返回 -1
+func findNode(nodes []model.Node, ip string) int {
+	for i, node := range nodes {
+		if node.IP == ip {
+			return i
+		}
+	}
+	return -1
+}
+
+// filterNodes 按IP过滤节点，keep 为 true 时保留列表中的节点，否则去掉列表中的节点
+func filterNodes(nodes []model.Node, ips []string, keep bool) []model.Node {
+	set := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		set[ip] = true
+	}
+
+	var filtered []model.Node
+	for _, node := range nodes {
+		if set[node.IP] == keep {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// replCompleter 为交互式会话提供补全：元命令、组名和已记录的命令
+type replCompleter struct {
+	mu       sync.Mutex
+	commands []string // 按最近使用排列，去重
+}
+
+// setCommands 设置可补全的历史命令
+func (c *replCompleter) setCommands(commands []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.commands = append([]string(nil), commands...)
+}
+
+// addCommand 将刚执行的命令移到最前
+func (c *replCompleter) addCommand(command string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, existing := range c.commands {
+		if existing == command {
+			c.commands = append(c.commands[:i], c.commands[i+1:]...)
+			break
+		}
+	}
+	c.commands = append([]string{command}, c.commands...)
+}
+
+// Do 实现 readline.AutoCompleter，返回候选项中光标前文本之后的部分
+func (c *replCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	prefix := string(line[:pos])
+
+	var candidates []string
+	word := prefix
+	if strings.HasPrefix(prefix, ":") {
+		name, arg, hasArg := strings.Cut(prefix, " ")
+		if !hasArg {
+			for _, meta := range metaCommands {
+				candidates = append(candidates, meta.name+" ")
+			}
+		} else {
+			word = strings.TrimLeft(arg, " ")
+			switch name {
+			case ":merge":
+				candidates = []string{"on", "off"}
+			case ":group":
+				if groups, err := crud.ListGroups(); err == nil {
+					for _, group := range groups {
+						candidates = append(candidates, group.Name)
+					}
+					sort.Strings(candidates)
+				}
+			}
+		}
+	} else {
+		c.mu.Lock()
+		candidates = append(candidates, c.commands...)
+		c.mu.Unlock()
+	}
+
+	var suffixes [][]rune
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) && candidate != word {
+			suffixes = append(suffixes, []rune(candidate[len(word):]))
+		}
+	}
+	return suffixes, len([]rune(word))
+}
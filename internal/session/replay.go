@@ -68,7 +68,27 @@ func ReplaySession(sessionID string) error {
 		model.DB.Find(&outputs, "command_id = ?", cmd.ID)
 
 		for _, output := range outputs {
-			fmt.Printf("[%s] 输出:\n%s\n", output.NodeIP, output.Output)
+			text, err := OutputText(output)
+			if err != nil {
+				text = fmt.Sprintf("(解压输出失败: %v)", err)
+			}
+			fmt.Printf("[%s] 输出:\n%s\n", output.NodeIP, text)
+
+			stderr, err := StderrText(output)
+			if err != nil {
+				stderr = fmt.Sprintf("(解压标准错误失败: %v)", err)
+			}
+			if stderr != "" {
+				fmt.Printf("[%s] 标准错误:\n%s\n", output.NodeIP, stderr)
+			}
+
+			if output.TimedOut {
+				fmt.Printf("[%s] 命令执行超时\n", output.NodeIP)
+			} else if output.Signal != "" {
+				fmt.Printf("[%s] 被信号 %s 终止\n", output.NodeIP, output.Signal)
+			} else if output.ExitCode != 0 {
+				fmt.Printf("[%s] 退出码: %d\n", output.NodeIP, output.ExitCode)
+			}
 		}
 
 		fmt.Printf("退出码: %d, 耗时: %dms\n", cmd.ExitCode, cmd.Duration)
@@ -0,0 +1,32 @@
+//go:build !windows
+
+package session
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// watchResize 在本地终端窗口大小变化时调用 onResize，返回停止监听的函数
+func watchResize(onResize func()) func() {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGWINCH)
+
+	go func() {
+		for {
+			select {
+			case <-ch:
+				onResize()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
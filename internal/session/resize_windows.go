@@ -0,0 +1,8 @@
+//go:build windows
+
+package session
+
+// watchResize Windows 下没有 SIGWINCH，不转发窗口大小变化
+func watchResize(onResize func()) func() {
+	return func() {}
+}
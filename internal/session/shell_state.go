@@ -0,0 +1,149 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+)
+
+// envFuncName 是会话中列出需要跟踪的导出变量的 shell 函数
+// 随目录切换或shell自身变化的变量不参与跟踪
+const envFuncName = "__cm_env"
+
+// envFuncDef 定义 envFuncName，在初始化shell时执行
+const envFuncDef = envFuncName + "() { export -p | grep -Ev '^(declare -x|export) (OLDPWD|PWD|SHLVL|_)(=|$)'; }"
+
+// shellState 是长会话中需要在重建时恢复的状态
+type shellState struct {
+	cwd string
+	env map[string]string // 用户导出的变量，值为 shell 中的写法
+}
+
+// state 返回会话当前的工作目录和用户导出的变量
+func (ns *NodeSession) state() shellState {
+	env := make(map[string]string, len(ns.environmentVars))
+	for name, value := range ns.environmentVars {
+		env[name] = value
+	}
+	return shellState{cwd: ns.cwd, env: env}
+}
+
+// Cwd 返回最近一条命令结束时的工作目录，未分配伪终端执行时为空
+func (ns *NodeSession) Cwd() string {
+	return ns.cwd
+}
+
+// restoreState 记录新shell的初始导出变量，然后恢复会话中保存的工作目录和环境变量
+// 工作目录已不存在等导致的恢复失败不影响会话使用
+func (ns *NodeSession) restoreState() error {
+	saved := ns.state()
+
+	ns.baseEnv = nil
+	out, err := ns.ExecuteCommand(envFuncName, 3*time.Second)
+	if err != nil {
+		return fmt.Errorf("读取环境变量失败: %v", err)
+	}
+	ns.baseEnv = parseExports(out)
+
+	var cmds []string
+	if saved.cwd != "" {
+		cmds = append(cmds, "cd "+utils.ShellQuote(saved.cwd))
+	}
+	names := make([]string, 0, len(saved.env))
+	for name := range saved.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmds = append(cmds, fmt.Sprintf("export %s=%s", name, saved.env[name]))
+	}
+	if len(cmds) > 0 {
+		ns.ExecuteCommand(strings.Join(cmds, "; "), 3*time.Second)
+	}
+	return nil
+}
+
+// trackState 根据结束标记更新工作目录，导出变量的校验和变化时重新读取用户导出的变量
+func (ns *NodeSession) trackState(envSum, cwd string) {
+	if cwd != "" {
+		ns.cwd = cwd
+	}
+	if envSum == "" || envSum == ns.envSum {
+		return
+	}
+	ns.envSum = envSum
+	// 还在记录初始导出变量
+	if ns.baseEnv == nil {
+		return
+	}
+
+	out, err := ns.ExecuteCommand(envFuncName, 3*time.Second)
+	if err != nil {
+		return
+	}
+	vars := make(map[string]string)
+	for name, value := range parseExports(out) {
+		if base, ok := ns.baseEnv[name]; !ok || base != value {
+			vars[name] = value
+		}
+	}
+	ns.environmentVars = vars
+}
+
+// parseExports 解析 export -p 的输出，返回变量名到 shell 写法的值的映射
+// 兼容 bash 的 declare -x 和 POSIX shell 的 export 两种格式，值中的换行保留在续行中
+func parseExports(out string) map[string]string {
+	env := make(map[string]string)
+	last := ""
+	for _, line := range strings.Split(out, "\n") {
+		rest, ok := strings.CutPrefix(line, "declare -x ")
+		if !ok {
+			rest, ok = strings.CutPrefix(line, "export ")
+		}
+		if !ok {
+			if last != "" {
+				env[last] += "\n" + line
+			}
+			continue
+		}
+		name, value, _ := strings.Cut(rest, "=")
+		env[name] = value
+		last = name
+	}
+	return env
+}
+
+// displayCwdDrift 显示工作目录与多数节点不同的节点，所有节点一致时不显示
+func displayCwdDrift(nodes []model.Node, results map[string]ExecResult) {
+	byCwd := make(map[string][]string)
+	for _, node := range nodes {
+		result, ok := results[node.IP]
+		if !ok || result.Cwd == "" {
+			continue
+		}
+		byCwd[result.Cwd] = append(byCwd[result.Cwd], node.IP)
+	}
+	if len(byCwd) < 2 {
+		return
+	}
+
+	cwds := make([]string, 0, len(byCwd))
+	for cwd := range byCwd {
+		cwds = append(cwds, cwd)
+	}
+	sort.Strings(cwds)
+	sort.SliceStable(cwds, func(i, j int) bool { return groupBefore(byCwd[cwds[i]], byCwd[cwds[j]]) })
+
+	majority := cwds[0]
+	color.Yellow("工作目录不一致，%d 个节点在 %s，其余节点:", len(byCwd[majority]), majority)
+	for _, cwd := range cwds[1:] {
+		ips := byCwd[cwd]
+		fmt.Printf("  [%s] %s\n", CompressIPList(ips), cwd)
+	}
+	fmt.Print("\n")
+}
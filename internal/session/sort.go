@@ -0,0 +1,109 @@
+package session
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// 结果排序方式
+const (
+	SortIP       = "ip"
+	SortDuration = "duration"
+	SortExitCode = "exit-code"
+)
+
+// ValidSort 判断排序方式是否有效
+func ValidSort(by string) bool {
+	return by == SortIP || by == SortDuration || by == SortExitCode
+}
+
+// compareIP 按数值比较点分格式的IP或子网，非数字段按字符串比较
+func compareIP(a, b string) int {
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.Atoi(pa[i])
+		nb, errB := strconv.Atoi(pb[i])
+		if errA == nil && errB == nil {
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+		if c := strings.Compare(pa[i], pb[i]); c != 0 {
+			return c
+		}
+	}
+	return len(pa) - len(pb)
+}
+
+// sortIPs 按数值顺序排序IP列表
+func sortIPs(ips []string) {
+	sort.SliceStable(ips, func(i, j int) bool { return compareIP(ips[i], ips[j]) < 0 })
+}
+
+// sortedSubnets 返回按数值顺序排列的子网
+func sortedSubnets(nodesBySubnet map[string][]model.Node) []string {
+	subnets := make([]string, 0, len(nodesBySubnet))
+	for subnet := range nodesBySubnet {
+		subnets = append(subnets, subnet)
+	}
+	sortIPs(subnets)
+	return subnets
+}
+
+// sortNodes 返回按指定方式排序的节点副本，相同时按IP排序
+// 按耗时排序时耗时长的在前，按退出码排序时失败的在前
+func sortNodes(nodes []model.Node, results map[string]ExecResult, by string) []model.Node {
+	sorted := make([]model.Node, len(nodes))
+	copy(sorted, nodes)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := results[sorted[i].IP], results[sorted[j].IP]
+		switch by {
+		case SortDuration:
+			if a.Duration != b.Duration {
+				return a.Duration > b.Duration
+			}
+		case SortExitCode:
+			if ra, rb := exitCodeRank(a), exitCodeRank(b); ra != rb {
+				return ra > rb
+			}
+		}
+		return compareIP(sorted[i].IP, sorted[j].IP) < 0
+	})
+	return sorted
+}
+
+// exitCodeRank 返回按退出码排序时的权重：超时和未执行的节点最靠前，其次按退出码从大到小，成功的在最后
+func exitCodeRank(result ExecResult) int {
+	switch {
+	case result.TimedOut:
+		return 1 << 20
+	case result.ExitCode < 0:
+		return 1 << 19
+	case !result.Success && result.ExitCode == 0:
+		// 健康检查等失败但命令本身退出码为 0
+		return 1
+	}
+	return result.ExitCode
+}
+
+// failureClass 返回失败结果的类别，用于合并输出中失败分组的排序
+// 依次为：未执行（连接等错误）、超时、被信号终止、非零退出码
+func failureClass(result ExecResult) int {
+	switch {
+	case result.TimedOut:
+		return 1
+	case result.Signal != "":
+		return 2
+	case result.ExitCode > 0:
+		return 3
+	}
+	return 0
+}
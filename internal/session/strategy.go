@@ -0,0 +1,193 @@
+package session
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+
+	"github.com/fatih/color"
+)
+
+// Strategy 描述分批（滚动）执行策略
+type Strategy struct {
+	BatchSize         int           // 每批节点数
+	BatchPercent      int           // 每批节点占总数的百分比，BatchSize 未设置时生效
+	Pause             time.Duration // 批次之间的等待时间
+	HealthCheck       string        // 每批执行后在该批节点上运行的健康检查命令，失败计入失败数
+	Canary            int           // 先在 K 个节点上执行，确认后再继续
+	MaxFailures       int           // 累计失败节点数超过该值时中止后续批次，0 表示出现失败即中止，负数表示不限制
+	MaxFailurePercent int           // 累计失败节点占已执行节点的百分比超过该值时中止，负数表示不限制
+}
+
+// Enabled 判断是否需要分批执行
+func (s Strategy) Enabled() bool {
+	return s.BatchSize > 0 || s.BatchPercent > 0 || s.Canary > 0
+}
+
+// ParseMaxFailures 解析失败阈值，支持数量（如 3）和百分比（如 10%）
+// 未设置的阈值返回 -1 表示不限制，0 表示出现失败即中止
+func ParseMaxFailures(value string) (count int, percent int, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return -1, -1, nil
+	}
+
+	if strings.HasSuffix(value, "%") {
+		percent, err = strconv.Atoi(strings.TrimSuffix(value, "%"))
+		if err != nil || percent < 0 || percent > 100 {
+			return -1, -1, fmt.Errorf("无效的失败百分比: %s", value)
+		}
+		return -1, percent, nil
+	}
+
+	count, err = strconv.Atoi(value)
+	if err != nil || count < 0 {
+		return -1, -1, fmt.Errorf("无效的失败数量: %s", value)
+	}
+	return count, -1, nil
+}
+
+// batches 按策略把节点拆分为批次，金丝雀节点单独作为第一批
+func (s Strategy) batches(nodes []model.Node) [][]model.Node {
+	var batches [][]model.Node
+
+	rest := nodes
+	if s.Canary > 0 {
+		k := s.Canary
+		if k > len(rest) {
+			k = len(rest)
+		}
+		batches = append(batches, rest[:k])
+		rest = rest[k:]
+	}
+
+	size := s.BatchSize
+	if size <= 0 && s.BatchPercent > 0 {
+		size = (len(nodes)*s.BatchPercent + 99) / 100
+	}
+	if size <= 0 {
+		size = len(rest)
+	}
+
+	for len(rest) > 0 {
+		n := size
+		if n > len(rest) {
+			n = len(rest)
+		}
+		batches = append(batches, rest[:n])
+		rest = rest[n:]
+	}
+	return batches
+}
+
+// exceeded 判断失败数是否超过中止阈值
+func (s Strategy) exceeded(failures, processed int) bool {
+	if s.MaxFailures >= 0 && failures > s.MaxFailures {
+		return true
+	}
+	if s.MaxFailurePercent >= 0 && processed > 0 && failures*100 > s.MaxFailurePercent*processed {
+		return true
+	}
+	return false
+}
+
+// executeWithStrategy 按策略分批执行命令
+// runBatch 负责在一批节点上执行命令，金丝雀批次完成后用 showCanary 显示结果、confirm 询问是否继续
+// 中止后未执行的节点不会出现在返回结果中
+func executeWithStrategy(
+	nodes []model.Node,
+	command string,
+	strategy Strategy,
+	runBatch func(batch []model.Node, command string) map[string]ExecResult,
+	confirm func(prompt string) bool,
+	showCanary func(batch []model.Node, results map[string]ExecResult),
+) map[string]ExecResult {
+	results := make(map[string]ExecResult)
+	batches := strategy.batches(nodes)
+
+	failures, processed := 0, 0
+	for i, batch := range batches {
+		label := fmt.Sprintf("批次 %d/%d", i+1, len(batches))
+		if i == 0 && strategy.Canary > 0 {
+			label = "金丝雀批次"
+		}
+		color.Cyan("%s: %s", label, CompressIPList(nodeIPs(batch)))
+
+		batchResults := runBatch(batch, command)
+		batchFailures := 0
+		for ip, result := range batchResults {
+			results[ip] = result
+			if !result.Success {
+				batchFailures++
+			}
+		}
+
+		// 健康检查失败的节点同样计为失败
+		if strategy.HealthCheck != "" {
+			checkResults := runBatch(batch, strategy.HealthCheck)
+			var unhealthy []string
+			for _, node := range batch {
+				check, ok := checkResults[node.IP]
+				if ok && check.Success {
+					continue
+				}
+				unhealthy = append(unhealthy, node.IP)
+				if result, ok := results[node.IP]; ok && result.Success {
+					result.Success = false
+					result.Error = fmt.Errorf("健康检查失败: %s", strings.TrimSpace(check.Output))
+					results[node.IP] = result
+					batchFailures++
+				}
+			}
+			if len(unhealthy) > 0 {
+				color.Red("健康检查失败: %s", CompressIPList(unhealthy))
+			}
+		}
+
+		failures += batchFailures
+		processed += len(batch)
+		summary := fmt.Sprintf("%s 完成: 成功 %d, 失败 %d（累计失败 %d/%d）", label, len(batch)-batchFailures, batchFailures, failures, processed)
+		if batchFailures > 0 {
+			color.Yellow(summary)
+		} else {
+			color.Green(summary)
+		}
+
+		remaining := remainingNodes(batches[i+1:])
+		if len(remaining) == 0 {
+			break
+		}
+
+		if strategy.exceeded(failures, processed) {
+			color.Red("失败数超过阈值，中止执行，未执行节点: %s", CompressIPList(nodeIPs(remaining)))
+			break
+		}
+
+		// 金丝雀批次需要确认结果后才继续
+		if i == 0 && strategy.Canary > 0 {
+			showCanary(batch, batchResults)
+			if !confirm(fmt.Sprintf("金丝雀批次已完成，是否继续在剩余 %d 个节点上执行? [y/N]: ", len(remaining))) {
+				color.Yellow("已取消，未执行节点: %s", CompressIPList(nodeIPs(remaining)))
+				break
+			}
+		}
+
+		if strategy.Pause > 0 {
+			color.Cyan("等待 %s 后执行下一批...", strategy.Pause)
+			time.Sleep(strategy.Pause)
+		}
+	}
+
+	return results
+}
+
+// remainingNodes 展开剩余批次中的节点
+func remainingNodes(batches [][]model.Node) []model.Node {
+	var nodes []model.Node
+	for _, batch := range batches {
+		nodes = append(nodes, batch...)
+	}
+	return nodes
+}
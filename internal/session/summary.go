@@ -0,0 +1,54 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"zhaowanpeng/cluster-manager/model"
+)
+
+// MergedSummary 返回合并结果的纯文本摘要，不分子网，用于比较两次执行的结果是否变化
+// normalizer 不为 nil 时摘要使用归一化后的输出，因节点而异或每次都变的部分不会被视为变化
+func MergedSummary(nodes []model.Node, results map[string]ExecResult, normalizer *Normalizer) string {
+	outputGroups := make(map[string]*outputGroup)
+	errorGroups := make(map[string][]string)
+	errorClasses := make(map[string]int)
+
+	for _, node := range sortNodes(nodes, nil, SortIP) {
+		result, ok := results[node.IP]
+		if !ok {
+			continue
+		}
+		if !result.Success {
+			label, _ := failureLabel(result)
+			errorGroups[label] = append(errorGroups[label], node.IP)
+			errorClasses[label] = failureClass(result)
+			continue
+		}
+		addOutputGroup(outputGroups, result, normalizer)
+	}
+
+	var buf strings.Builder
+	for _, label := range sortedErrorLabels(errorGroups, errorClasses) {
+		fmt.Fprintf(&buf, "[%s] %s\n", CompressIPList(errorGroups[label]), label)
+	}
+	for _, group := range sortedOutputGroups(outputGroups, groupBefore) {
+		fmt.Fprintf(&buf, "[%s]\n", CompressIPList(group.ips))
+		if group.key != "" {
+			fmt.Fprintf(&buf, "%s\n", strings.TrimRight(group.key, "\n"))
+		}
+
+		// 标准错误不同的节点单独列出
+		stderrs := make([]*stderrGroup, 0, len(group.byStderr))
+		for _, stderr := range group.byStderr {
+			if stderr.key != "" {
+				stderrs = append(stderrs, stderr)
+			}
+		}
+		sort.Slice(stderrs, func(i, j int) bool { return groupBefore(stderrs[i].ips, stderrs[j].ips) })
+		for _, stderr := range stderrs {
+			fmt.Fprintf(&buf, "[%s] 标准错误:\n%s\n", CompressIPList(stderr.ips), strings.TrimRight(stderr.key, "\n"))
+		}
+	}
+	return buf.String()
+}
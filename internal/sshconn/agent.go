@@ -0,0 +1,59 @@
+package sshconn
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"zhaowanpeng/cluster-manager/internal/utils"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// 未指定私钥时依次尝试的默认私钥
+var defaultKeyFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+var (
+	agentOnce   sync.Once
+	agentClient agent.ExtendedAgent
+)
+
+// sshAgent 返回 SSH_AUTH_SOCK 指向的 SSH agent，未设置或无法连接时返回 nil
+// 连接在进程内复用，网关代理模式下即为客户端转发过来的 agent
+func sshAgent() agent.ExtendedAgent {
+	agentOnce.Do(func() {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return
+		}
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return
+		}
+		agentClient = agent.NewClient(conn)
+	})
+	return agentClient
+}
+
+// agentAuthMethods 使用 SSH agent 中的密钥和当前用户的默认私钥认证，不使用密码
+func agentAuthMethods() ([]ssh.AuthMethod, error) {
+	var signers []ssh.Signer
+	if a := sshAgent(); a != nil {
+		if agentSigners, err := a.Signers(); err == nil {
+			signers = append(signers, agentSigners...)
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		for _, name := range defaultKeyFiles {
+			if signer, err := utils.LoadSigner(filepath.Join(home, ".ssh", name)); err == nil {
+				signers = append(signers, signer)
+			}
+		}
+	}
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("没有可用的 SSH agent 或默认私钥，可为网关开启 --send-credentials")
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, nil
+}
@@ -0,0 +1,320 @@
+package sshconn
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/internal/utils"
+	"zhaowanpeng/cluster-manager/model"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// bastion 是被多个目标节点共享的跳板机连接
+// refs 由 bastionsMu 保护；client 由 mu 保护，建连和检查连接只锁定该跳板机
+type bastion struct {
+	mu     sync.Mutex
+	client *ssh.Client
+	refs   int
+}
+
+var (
+	bastionsMu sync.Mutex
+	bastions   = make(map[string]*bastion) // 跳转链前缀 -> 跳板机连接
+)
+
+// ClientConfig 根据节点的认证信息构造SSH客户端配置
+func ClientConfig(node model.Node, timeout time.Duration) (*ssh.ClientConfig, error) {
+	auth, err := authMethods(node)
+	if err != nil {
+		return nil, fmt.Errorf("节点 %s 认证配置无效: %v", node.IP, err)
+	}
+	return &ssh.ClientConfig{
+		User:            node.User,
+		Auth:            auth,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         timeout,
+	}, nil
+}
+
+// authMethods 按节点的认证方式构造认证方法
+// 密钥认证的节点只使用私钥，agent 认证的节点使用 SSH agent 和默认私钥，其余节点私钥优先、密码作为后备
+func authMethods(node model.Node) ([]ssh.AuthMethod, error) {
+	if node.AuthType == model.AuthAgent {
+		return agentAuthMethods()
+	}
+	if node.AuthType == model.AuthKey {
+		if node.KeyPath == "" {
+			return nil, fmt.Errorf("使用密钥认证但没有配置私钥")
+		}
+		signer, err := utils.LoadSigner(node.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+	return utils.AuthMethods(node.Password, node.KeyPath)
+}
+
+// Addr 返回节点的SSH地址
+func Addr(node model.Node) string {
+	return net.JoinHostPort(node.IP, strconv.Itoa(node.Port))
+}
+
+// ParseJumpHosts 拆分逗号分隔的跳板机列表
+func ParseJumpHosts(jumpHosts string) []string {
+	var ids []string
+	for _, id := range strings.Split(jumpHosts, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// JumpChain 解析节点的跳板机链，节点未配置时使用所在组的配置
+func JumpChain(node model.Node) ([]model.Node, error) {
+	jumpHosts := node.JumpHosts
+	if jumpHosts == "" && node.Group != "" && model.DB != nil {
+		var group model.Group
+		if result := model.DB.Where("`name` = ?", node.Group).Limit(1).Find(&group); result.Error == nil {
+			jumpHosts = group.JumpHosts
+		}
+	}
+
+	ids := ParseJumpHosts(jumpHosts)
+	chain := make([]model.Node, 0, len(ids))
+	for _, id := range ids {
+		if id == node.ID {
+			return nil, fmt.Errorf("节点 %s 不能作为自己的跳板机", node.IP)
+		}
+		var hop model.Node
+		result := model.DB.Where("id = ?", id).Limit(1).Find(&hop)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, fmt.Errorf("跳板机节点 '%s' 不存在", id)
+		}
+		chain = append(chain, hop)
+	}
+	return chain, nil
+}
+
+// Dial 建立到节点的SSH连接，配置了跳板机时通过跳板机链逐级转发
+// 同一跳板机链上的连接被所有目标节点共享，最后一个目标断开后自动关闭
+func Dial(node model.Node, timeout time.Duration) (*ssh.Client, error) {
+	config, err := ClientConfig(node, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	chain, err := JumpChain(node)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return dialDirect(node, config, timeout)
+	}
+
+	keys, hop, err := acquireChain(chain, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := dialVia(hop, node, config, timeout)
+	if err != nil {
+		releaseChain(keys)
+		return nil, fmt.Errorf("通过跳板机 %s 连接失败: %v", chain[len(chain)-1].IP, err)
+	}
+
+	// 目标连接断开后释放对跳板机的引用
+	go func() {
+		client.Wait()
+		releaseChain(keys)
+	}()
+
+	return client, nil
+}
+
+// Check 验证节点能否登录并创建会话
+// 池中已有连接时复用；为验证新建的连接在验证后关闭，批量验证大量节点时不会一直占用连接
+func Check(node model.Node, timeout time.Duration) (bool, string) {
+	client, created, err := Shared.client(node, timeout)
+	if err != nil {
+		return false, err.Error()
+	}
+	if created {
+		defer Shared.release(node, client)
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return false, fmt.Sprintf("Failed to create session: %s", err.Error())
+	}
+	session.Close()
+
+	return true, "ssh success"
+}
+
+// acquireChain 逐级获取跳板机连接并增加引用计数，返回链上每一级的键和最后一级的连接
+// 全局锁只用于查找和引用计数，建连在各跳板机自己的锁内进行，一个跳板机响应慢不影响其他连接
+func acquireChain(chain []model.Node, timeout time.Duration) ([]string, *ssh.Client, error) {
+	var keys []string
+	var prev *ssh.Client
+	for i, hop := range chain {
+		key := chainKey(chain[:i+1])
+
+		// 先增加引用，建连期间该跳板机不会被其他目标释放
+		bastionsMu.Lock()
+		b, ok := bastions[key]
+		if !ok {
+			b = &bastion{}
+			bastions[key] = b
+		}
+		b.refs++
+		bastionsMu.Unlock()
+		keys = append(keys, key)
+
+		client, err := b.connect(hop, prev, timeout)
+		if err != nil {
+			releaseChain(keys)
+			return nil, nil, fmt.Errorf("连接跳板机 %s 失败: %v", hop.IP, err)
+		}
+		prev = client
+	}
+
+	return keys, prev, nil
+}
+
+// connect 返回跳板机的可用连接，没有连接或连接已失效时经 via（为空时直接）重新连接
+func (b *bastion) connect(hop model.Node, via *ssh.Client, timeout time.Duration) (*ssh.Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.client != nil && alive(b.client, timeout) {
+		return b.client, nil
+	}
+	if b.client != nil {
+		b.client.Close()
+		b.client = nil
+	}
+
+	config, err := ClientConfig(hop, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var client *ssh.Client
+	if via == nil {
+		client, err = dialDirect(hop, config, timeout)
+	} else {
+		client, err = dialVia(via, hop, config, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b.client = client
+	return client, nil
+}
+
+// releaseChain 释放对跳板机链的引用，从最远的一级开始关闭无人使用的连接
+// 引用为零时没有其他目标正在使用或连接该跳板机，可以在锁外关闭连接
+func releaseChain(keys []string) {
+	var unused []*bastion
+	bastionsMu.Lock()
+	for i := len(keys) - 1; i >= 0; i-- {
+		b, ok := bastions[keys[i]]
+		if !ok {
+			continue
+		}
+		b.refs--
+		if b.refs <= 0 {
+			delete(bastions, keys[i])
+			unused = append(unused, b)
+		}
+	}
+	bastionsMu.Unlock()
+
+	for _, b := range unused {
+		b.mu.Lock()
+		if b.client != nil {
+			b.client.Close()
+			b.client = nil
+		}
+		b.mu.Unlock()
+	}
+}
+
+// dialDirect 直接连接节点并完成SSH握手
+// ssh.Dial 的超时只作用于建立 TCP 连接，对端接受连接后不响应时握手会一直阻塞，因此握手期间设置读写截止时间
+func dialDirect(node model.Node, config *ssh.ClientConfig, timeout time.Duration) (*ssh.Client, error) {
+	addr := Addr(node)
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// dialVia 通过已有连接转发到目标节点并完成SSH握手
+func dialVia(via *ssh.Client, node model.Node, config *ssh.ClientConfig, timeout time.Duration) (*ssh.Client, error) {
+	addr := Addr(node)
+	conn, err := via.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// 转发通道不支持设置超时，握手超时时直接关闭底层连接
+	timer := time.AfterFunc(timeout, func() { conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() && err == nil {
+		sshConn.Close()
+		return nil, fmt.Errorf("SSH握手超时")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// alive 通过 keepalive 请求检查连接是否仍然可用，timeout 内没有回复时视为已断开
+// 调用方随后关闭连接，未返回的请求随之结束
+func alive(client *ssh.Client, timeout time.Duration) bool {
+	replied := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		replied <- err
+	}()
+
+	select {
+	case err := <-replied:
+		return err == nil
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// chainKey 返回跳板机链前缀的唯一键
+func chainKey(chain []model.Node) string {
+	ids := make([]string, len(chain))
+	for i, hop := range chain {
+		ids[i] = hop.ID
+	}
+	return strings.Join(ids, ">")
+}
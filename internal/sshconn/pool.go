@@ -0,0 +1,208 @@
+package sshconn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"zhaowanpeng/cluster-manager/model"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrExecTimeout 表示通过连接池执行的命令在超时时间内没有结束
+var ErrExecTimeout = errors.New("命令执行超时")
+
+// Pool 按节点缓存已认证的SSH连接，在同一连接上按需开启执行和shell通道
+// 连接一直保留到断开或被显式关闭，同一进程中对同一节点的执行和作业查询只需一次握手
+// 一次性的登录验证（Check）不会把新建的连接留在池中
+type Pool struct {
+	mu    sync.Mutex
+	conns map[string]*pooledConn
+}
+
+// pooledConn 是池中一个节点的连接，建连只锁定该节点
+type pooledConn struct {
+	mu     sync.Mutex
+	client *ssh.Client
+	closed chan struct{} // 连接断开时关闭
+}
+
+// Shared 是进程内共享的连接池
+var Shared = NewPool()
+
+// NewPool 创建空的连接池
+func NewPool() *Pool {
+	return &Pool{conns: make(map[string]*pooledConn)}
+}
+
+// Key 返回节点连接的唯一键，地址、用户和认证信息都相同的节点共用连接
+// 认证信息以摘要参与，修改密码或私钥后不会复用以旧凭据认证的连接，验证新凭据时总是重新握手
+func Key(node model.Node) string {
+	sum := sha256.Sum256([]byte(node.AuthType + "\x00" + node.Password + "\x00" + node.KeyPath))
+	return fmt.Sprintf("%s:%d:%s:%s", node.IP, node.Port, node.User, hex.EncodeToString(sum[:8]))
+}
+
+// Client 返回节点的SSH连接，没有可用连接时建立新连接
+// 返回的连接由连接池管理，调用方不应关闭
+func (p *Pool) Client(node model.Node, timeout time.Duration) (*ssh.Client, error) {
+	client, _, err := p.client(node, timeout)
+	return client, err
+}
+
+// client 返回节点的SSH连接，created 表示连接是本次调用新建的
+func (p *Pool) client(node model.Node, timeout time.Duration) (*ssh.Client, bool, error) {
+	key := Key(node)
+	p.mu.Lock()
+	pc, ok := p.conns[key]
+	if !ok {
+		pc = &pooledConn{}
+		p.conns[key] = pc
+	}
+	p.mu.Unlock()
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.client != nil && !pc.isClosed() {
+		return pc.client, false, nil
+	}
+
+	client, err := Dial(node, timeout)
+	if err != nil {
+		return nil, false, err
+	}
+	closed := make(chan struct{})
+	go func() {
+		client.Wait()
+		close(closed)
+	}()
+	pc.client, pc.closed = client, closed
+	return client, true, nil
+}
+
+// Connected 返回池中是否已有节点的可用连接，有时获取连接不需要握手
+func (p *Pool) Connected(node model.Node) bool {
+	p.mu.Lock()
+	pc, ok := p.conns[Key(node)]
+	p.mu.Unlock()
+	if !ok {
+		return false
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	return pc.client != nil && !pc.isClosed()
+}
+
+// isClosed 返回连接是否已断开，调用方需持有锁
+func (pc *pooledConn) isClosed() bool {
+	select {
+	case <-pc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// session 在节点的连接上开启新的会话通道
+func (p *Pool) session(node model.Node, timeout time.Duration) (*ssh.Session, error) {
+	client, err := p.Client(node, timeout)
+	if err != nil {
+		return nil, err
+	}
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	return session, nil
+}
+
+// Exec 在独立的执行通道中运行命令，分别返回标准输出和标准错误
+// 命令以非零状态退出时返回 *ssh.ExitError，超时时终止远程进程并返回 ErrExecTimeout
+func (p *Pool) Exec(node model.Node, command string, timeout time.Duration) (string, string, error) {
+	session, err := p.session(node, timeout)
+	if err != nil {
+		return "", "", err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	err = wait(session, command, timeout)
+	return stdout.String(), stderr.String(), err
+}
+
+// Close 关闭并移除节点的连接
+func (p *Pool) Close(node model.Node) {
+	p.mu.Lock()
+	pc, ok := p.conns[Key(node)]
+	delete(p.conns, Key(node))
+	p.mu.Unlock()
+	if ok {
+		pc.close()
+	}
+}
+
+// release 关闭并移除节点的连接，只在池中仍是 client 时关闭，已被替换的新连接不受影响
+func (p *Pool) release(node model.Node, client *ssh.Client) {
+	key := Key(node)
+	p.mu.Lock()
+	pc, ok := p.conns[key]
+	if ok {
+		pc.mu.Lock()
+		ok = pc.client == client
+		pc.mu.Unlock()
+	}
+	if ok {
+		delete(p.conns, key)
+	}
+	p.mu.Unlock()
+	if ok {
+		pc.close()
+	}
+}
+
+// CloseAll 关闭池中所有连接
+func (p *Pool) CloseAll() {
+	p.mu.Lock()
+	conns := p.conns
+	p.conns = make(map[string]*pooledConn)
+	p.mu.Unlock()
+
+	for _, pc := range conns {
+		pc.close()
+	}
+}
+
+// close 关闭连接
+func (pc *pooledConn) close() {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.client != nil {
+		pc.client.Close()
+		pc.client = nil
+	}
+}
+
+// wait 启动命令并等待结束，超时时向远程进程发送 SIGKILL
+func wait(session *ssh.Session, command string, timeout time.Duration) error {
+	if err := session.Start(command); err != nil {
+		return fmt.Errorf("启动命令失败: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		session.Signal(ssh.SIGKILL)
+		return ErrExecTimeout
+	}
+}
@@ -0,0 +1,24 @@
+package types
+
+// Facts 表示从节点采集的系统信息，以 JSON 保存在节点上
+type Facts struct {
+	Hostname       string   `json:"hostname"`
+	OS             string   `json:"os"`         // 发行版全称，如 Ubuntu 22.04.4 LTS
+	OSID           string   `json:"os_id"`      // 发行版标识，如 ubuntu、centos
+	OSVersion      string   `json:"os_version"` // 发行版版本号，如 22.04
+	Kernel         string   `json:"kernel"`
+	Arch           string   `json:"arch"`
+	CPUs           int      `json:"cpus"`
+	MemoryMB       int64    `json:"memory_mb"`
+	Disks          []Disk   `json:"disks"`
+	IPs            []string `json:"ips"`
+	Uptime         int64    `json:"uptime"`         // 运行时长（秒）
+	Virtualization string   `json:"virtualization"` // 虚拟化类型，如 kvm、docker，物理机为 none
+}
+
+// Disk 表示节点上挂载的一个块设备文件系统
+type Disk struct {
+	Mount  string `json:"mount"`
+	SizeMB int64  `json:"size_mb"`
+	UsedMB int64  `json:"used_mb"`
+}
@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDiffCells 限制 LCS 表的大小，避免超大输出占用过多内存
+const maxDiffCells = 4 * 1024 * 1024
+
+// diffOp 是编辑脚本中的一行：' ' 相同，'-' 仅在旧文本中，'+' 仅在新文本中
+type diffOp struct {
+	kind byte
+	text string
+	oldN int // 该行在旧文本中的行号（从 0 开始），'+' 行为插入位置
+	newN int // 该行在新文本中的行号（从 0 开始），'-' 行为插入位置
+}
+
+// UnifiedDiff 按行比较 a 和 b，返回 unified 格式的差异，context 为每个差异块前后的上下文行数
+// 两者相同时返回空字符串；输出过大无法比较时 ok 为 false
+func UnifiedDiff(oldName, newName, a, b string, context int) (diff string, ok bool) {
+	if a == b {
+		return "", true
+	}
+
+	oldLines := splitLines(a)
+	newLines := splitLines(b)
+	if (len(oldLines)+1)*(len(newLines)+1) > maxDiffCells {
+		return "", false
+	}
+
+	ops := diffLines(oldLines, newLines)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range diffHunks(ops, context) {
+		writeHunk(&buf, ops[hunk[0]:hunk[1]])
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), true
+}
+
+// splitLines 按行拆分文本，空文本视为零行
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// diffLines 通过最长公共子序列计算编辑脚本
+func diffLines(a, b []string) []diffOp {
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{' ', a[i], i, j})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, diffOp{'+', b[j], i, j})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		ops = append(ops, diffOp{'-', a[i], i, j})
+	}
+	for ; j < len(b); j++ {
+		ops = append(ops, diffOp{'+', b[j], i, j})
+	}
+	return ops
+}
+
+// diffHunks 返回各差异块在编辑脚本中的 [起, 止) 范围，相距不超过两倍上下文的差异合并为一块
+func diffHunks(ops []diffOp, context int) [][2]int {
+	var hunks [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].kind == ' ' {
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		if n := len(hunks); n > 0 && start <= hunks[n-1][1] {
+			start = hunks[n-1][0]
+			hunks = hunks[:n-1]
+		}
+
+		// 找到这一段连续差异的末尾
+		end := i
+		for end < len(ops) && ops[end].kind != ' ' {
+			end++
+		}
+		i = end - 1
+
+		end += context
+		if end > len(ops) {
+			end = len(ops)
+		}
+		hunks = append(hunks, [2]int{start, end})
+	}
+	return hunks
+}
+
+// writeHunk 写出一个差异块
+func writeHunk(buf *strings.Builder, ops []diffOp) {
+	oldCount, newCount := 0, 0
+	for _, op := range ops {
+		if op.kind != '+' {
+			oldCount++
+		}
+		if op.kind != '-' {
+			newCount++
+		}
+	}
+
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(ops[0].oldN, oldCount), hunkRange(ops[0].newN, newCount))
+	for _, op := range ops {
+		fmt.Fprintf(buf, "%c%s\n", op.kind, op.text)
+	}
+}
+
+// hunkRange 格式化差异块头中的行范围，行号从 1 开始，空范围指向前一行
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// GenerateKeyPair 生成 ed25519 密钥对，私钥写入 keyPath，公钥写入 keyPath.pub
+// 返回 authorized_keys 格式的公钥
+func GenerateKeyPair(keyPath, comment string) (string, error) {
+	if _, err := os.Stat(keyPath); err == nil {
+		return "", fmt.Errorf("私钥文件已存在: %s", keyPath)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("生成密钥失败: %v", err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(priv, comment)
+	if err != nil {
+		return "", fmt.Errorf("编码私钥失败: %v", err)
+	}
+
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("编码公钥失败: %v", err)
+	}
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(sshPub)))
+	if comment != "" {
+		authorizedKey += " " + comment
+	}
+
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return "", fmt.Errorf("创建密钥目录失败: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		return "", fmt.Errorf("写入私钥失败: %v", err)
+	}
+	if err := os.WriteFile(keyPath+".pub", []byte(authorizedKey+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("写入公钥失败: %v", err)
+	}
+
+	return authorizedKey, nil
+}
+
+// LoadAuthorizedKey 读取并校验公钥文件，返回单行 authorized_keys 格式的公钥
+func LoadAuthorizedKey(pubPath string) (string, error) {
+	data, err := os.ReadFile(pubPath)
+	if err != nil {
+		return "", fmt.Errorf("读取公钥失败: %v", err)
+	}
+
+	line := strings.TrimSpace(string(data))
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line)); err != nil {
+		return "", fmt.Errorf("无效的公钥: %v", err)
+	}
+	if strings.Contains(line, "\n") {
+		return "", fmt.Errorf("公钥文件只能包含一个公钥: %s", pubPath)
+	}
+	return line, nil
+}
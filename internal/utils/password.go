@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+// 随机密码字符集，去掉了引号、反斜杠等在 shell 和配置文件中容易出错的字符
+const (
+	passwordLower   = "abcdefghijkmnopqrstuvwxyz"
+	passwordUpper   = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+	passwordDigits  = "23456789"
+	passwordSymbols = "@#%+=_-.,"
+)
+
+// GeneratePassword 生成强随机密码，保证包含大小写字母、数字和符号
+func GeneratePassword(length int) (string, error) {
+	classes := []string{passwordLower, passwordUpper, passwordDigits, passwordSymbols}
+	if length < len(classes) {
+		return "", fmt.Errorf("密码长度不能小于 %d", len(classes))
+	}
+
+	all := passwordLower + passwordUpper + passwordDigits + passwordSymbols
+	password := make([]byte, length)
+
+	// 每类字符至少一个，其余位置从全集中选取
+	for i := range password {
+		charset := all
+		if i < len(classes) {
+			charset = classes[i]
+		}
+		c, err := randomChar(charset)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// 打乱顺序，避免固定位置的字符类别
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+// randomChar 从字符集中随机选取一个字符
+func randomChar(charset string) (byte, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+	if err != nil {
+		return 0, err
+	}
+	return charset[n.Int64()], nil
+}
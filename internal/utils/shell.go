@@ -0,0 +1,8 @@
+package utils
+
+import "strings"
+
+// ShellQuote 使用单引号转义字符串，使其可以安全地拼接到 shell 命令中
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -3,6 +3,8 @@ package utils
 import (
 	"fmt"
 	"net"
+	"os"
+	"strconv"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -10,12 +12,50 @@ import (
 
 func Get_SSH_Client() {}
 
+// AuthMethods 根据私钥路径和密码构造认证方式，私钥优先，密码作为后备
+func AuthMethods(password, keyPath string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if keyPath != "" {
+		signer, err := LoadSigner(keyPath)
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	// 未配置私钥时即使密码为空也尝试密码认证，与以往行为保持一致
+	if password != "" || keyPath == "" {
+		methods = append(methods, ssh.Password(password))
+	}
+
+	return methods, nil
+}
+
+// LoadSigner 读取私钥文件
+func LoadSigner(keyPath string) (ssh.Signer, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("读取私钥失败: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析私钥失败: %v", err)
+	}
+	return signer, nil
+}
+
 func SSH_Check(ip string, port int, user string, pwd string, timeout time.Duration) (*ssh.Client, string) {
-	addr := fmt.Sprintf("%s:%d", ip, port)
+	return SSH_CheckAuth(ip, port, user, []ssh.AuthMethod{ssh.Password(pwd)}, timeout)
+}
+
+// SSH_CheckAuth 使用指定的认证方式检查节点能否登录
+func SSH_CheckAuth(ip string, port int, user string, auth []ssh.AuthMethod, timeout time.Duration) (*ssh.Client, string) {
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
 
 	config := &ssh.ClientConfig{
 		User:            user,
-		Auth:            []ssh.AuthMethod{ssh.Password(pwd)},
+		Auth:            auth,
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
 		Timeout:         timeout,
 	}
@@ -25,11 +65,11 @@ func SSH_Check(ip string, port int, user string, pwd string, timeout time.Durati
 	if err != nil {
 		return nil, err.Error()
 	}
-	defer conn.Close()
 
 	// 尝试SSH连接
 	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
 	if err != nil {
+		conn.Close()
 		return nil, err.Error()
 	}
 
@@ -48,7 +88,7 @@ func SSH_Check(ip string, port int, user string, pwd string, timeout time.Durati
 }
 
 func Exec_SSH_Command(ip string, port int, user, pwd, command string, timeout time.Duration) (string, error) {
-	addr := fmt.Sprintf("%s:%d", ip, port)
+	addr := net.JoinHostPort(ip, strconv.Itoa(port))
 
 	config := &ssh.ClientConfig{
 		User:            user,
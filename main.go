@@ -8,11 +8,13 @@ import (
 )
 
 func main() {
-	// 初始化数据库
-	err := model.InitDB()
-	if err != nil {
-		fmt.Printf("初始化数据库失败: %v\n", err)
-		os.Exit(1)
+	// 初始化数据库，网关代理模式运行在远程节点上，不需要本地数据库
+	if len(os.Args) < 2 || os.Args[1] != "agent" {
+		err := model.InitDB()
+		if err != nil {
+			fmt.Printf("初始化数据库失败: %v\n", err)
+			os.Exit(1)
+		}
 	}
 
 	// 执行命令
@@ -0,0 +1,153 @@
+package model
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// BackupDB 在线备份数据库到指定文件
+// 使用 VACUUM INTO 生成一致性快照，备份期间无需停止其他读写
+func BackupDB(dest string) error {
+	if DB == nil {
+		return fmt.Errorf("数据库未初始化")
+	}
+
+	absDest, err := filepath.Abs(dest)
+	if err != nil {
+		return fmt.Errorf("无法解析备份路径: %v", err)
+	}
+
+	// VACUUM INTO 要求目标文件不存在
+	if _, err := os.Stat(absDest); err == nil {
+		return fmt.Errorf("备份文件已存在: %s", absDest)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(absDest), 0755); err != nil {
+		return fmt.Errorf("无法创建备份目录: %v", err)
+	}
+
+	if err := DB.Exec("VACUUM INTO ?", absDest).Error; err != nil {
+		return fmt.Errorf("备份数据库失败: %v", err)
+	}
+
+	return nil
+}
+
+// RestoreDB 从备份文件恢复数据库
+// 恢复前会校验备份文件，并将当前数据库保留为 cluster.db.before-restore
+func RestoreDB(src string) error {
+	if err := verifyBackup(src); err != nil {
+		return err
+	}
+
+	dbPath, err := DBPath()
+	if err != nil {
+		return err
+	}
+
+	// 先将备份复制到临时文件，确保替换操作是原子的
+	tmpPath := dbPath + ".restore"
+	if err := copyFile(src, tmpPath); err != nil {
+		return fmt.Errorf("复制备份文件失败: %v", err)
+	}
+
+	// 关闭当前连接，避免替换文件时仍有未完成的写入
+	if err := CloseDB(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("关闭数据库失败: %v", err)
+	}
+
+	// 之后的步骤失败时重新打开原数据库，避免后续操作（如守护进程）使用已关闭的连接
+	reopen := func(err error) error {
+		if initErr := InitDB(); initErr != nil {
+			return fmt.Errorf("%v; 重新打开数据库失败: %v", err, initErr)
+		}
+		return err
+	}
+
+	// 保留当前数据库，便于误操作后找回
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := copyFile(dbPath, dbPath+".before-restore"); err != nil {
+			os.Remove(tmpPath)
+			return reopen(fmt.Errorf("保留当前数据库失败: %v", err))
+		}
+	}
+
+	// 清理旧的日志文件，否则 SQLite 可能把它们回放到新数据库上
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		os.Remove(dbPath + suffix)
+	}
+
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return reopen(fmt.Errorf("替换数据库文件失败: %v", err))
+	}
+
+	return InitDB()
+}
+
+// verifyBackup 校验备份文件是否为完整的数据库
+func verifyBackup(src string) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("无法读取备份文件: %v", err)
+	}
+
+	db, err := gorm.Open(sqlite.Open(src), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return fmt.Errorf("无法打开备份文件: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	var result string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&result).Error; err != nil {
+		return fmt.Errorf("校验备份文件失败: %v", err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("备份文件已损坏: %s", result)
+	}
+
+	// 至少要包含节点表和组表
+	for _, table := range []string{"nodes", "groups"} {
+		if !db.Migrator().HasTable(table) {
+			return fmt.Errorf("备份文件缺少表: %s", table)
+		}
+	}
+
+	return nil
+}
+
+// copyFile 复制文件
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
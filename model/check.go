@@ -0,0 +1,27 @@
+package model
+
+import "time"
+
+// NodeCheck 表示一次节点健康检查的结果，依次检查 TCP 可达、SSH 认证和 shell 启动
+type NodeCheck struct {
+	ID        string    `gorm:"primaryKey"`
+	NodeID    string    `gorm:"index"`
+	NodeIP    string    `gorm:""`
+	GroupName string    `gorm:"index"`
+	CheckedAt time.Time `gorm:"index"`
+
+	Reachable bool `gorm:"default:false"` // TCP 端口可连接，经跳板机的节点以 SSH 连接结果为准
+	AuthOK    bool `gorm:"default:false"` // SSH 认证成功
+	ShellOK   bool `gorm:"default:false"` // 能启动 shell 执行命令
+	Usable    bool `gorm:"default:false"` // 以上全部通过
+
+	TCPLatency   int64  `gorm:""` // TCP 建连耗时（毫秒）
+	SSHLatency   int64  `gorm:""` // SSH 握手和认证耗时（毫秒）
+	ShellLatency int64  `gorm:""` // 执行一条命令的往返耗时（毫秒）
+	Error        string `gorm:""` // 第一个失败阶段的错误
+}
+
+// TableName 指定表名
+func (NodeCheck) TableName() string {
+	return "node_checks"
+}
@@ -13,22 +13,39 @@ import (
 // DB 是全局数据库连接
 var DB *gorm.DB
 
-// InitDB 初始化数据库连接
-func InitDB() error {
+// AppDir 返回应用数据目录，不存在时自动创建
+func AppDir() (string, error) {
 	// 获取用户主目录
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("无法获取用户主目录: %v", err)
+		return "", fmt.Errorf("无法获取用户主目录: %v", err)
 	}
 
 	// 创建应用数据目录
 	appDir := filepath.Join(homeDir, ".cluster-manager")
 	if err := os.MkdirAll(appDir, 0755); err != nil {
-		return fmt.Errorf("无法创建应用数据目录: %v", err)
+		return "", fmt.Errorf("无法创建应用数据目录: %v", err)
 	}
 
+	return appDir, nil
+}
+
+// DBPath 返回数据库文件路径
+func DBPath() (string, error) {
+	appDir, err := AppDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, "cluster.db"), nil
+}
+
+// InitDB 初始化数据库连接
+func InitDB() error {
 	// 数据库文件路径
-	dbPath := filepath.Join(appDir, "cluster.db")
+	dbPath, err := DBPath()
+	if err != nil {
+		return err
+	}
 
 	// 连接数据库 - 使用纯 Go 实现的 SQLite 驱动
 	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
@@ -39,7 +56,7 @@ func InitDB() error {
 	}
 
 	// 自动迁移表结构
-	err = db.AutoMigrate(&Node{}, &Group{}, &Session{}, &Command{}, &CommandOutput{})
+	err = db.AutoMigrate(&Node{}, &Group{}, &Session{}, &Command{}, &CommandOutput{}, &Gateway{}, &Job{}, &JobNode{}, &Schedule{}, &NodeCheck{})
 	if err != nil {
 		return fmt.Errorf("自动迁移表结构失败: %v", err)
 	}
@@ -47,3 +64,16 @@ func InitDB() error {
 	DB = db
 	return nil
 }
+
+// CloseDB 关闭数据库连接
+func CloseDB() error {
+	if DB == nil {
+		return nil
+	}
+	sqlDB, err := DB.DB()
+	if err != nil {
+		return err
+	}
+	DB = nil
+	return sqlDB.Close()
+}
@@ -0,0 +1,18 @@
+package model
+
+// Gateway 表示组内某个子网的网关节点
+// 该子网的节点由网关就近连接执行，结果通过网关的SSH通道返回
+type Gateway struct {
+	ID        string `gorm:"primaryKey"` // 组名-子网
+	GroupName string `gorm:"index"`
+	Subnet    string `gorm:""` // 与结果显示一致的 /24 子网前缀，如 192.168.1
+	NodeID    string `gorm:""`
+	// SendCredentials 为 true 时把目标节点的密码和私钥内容发给网关代理
+	// 默认不发送，网关通过转发的 SSH agent 或网关上用户自己的私钥连接目标节点
+	SendCredentials bool `gorm:"default:false"`
+}
+
+// TableName 指定表名
+func (Gateway) TableName() string {
+	return "gateways"
+}
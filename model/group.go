@@ -12,6 +12,9 @@ type Group struct {
 	UpdatedAt   time.Time `gorm:""`
 	User        string    `gorm:""`
 	Tmp         bool      `gorm:"default:false"`
+	JumpHosts   string    `gorm:""`          // 逗号分隔的跳板机节点ID，按顺序逐级跳转
+	Fanout      int       `gorm:"default:0"` // 并发处理的节点数上限，0 表示使用默认值
+	DialRate    float64   `gorm:"default:0"` // 每秒新建连接数上限，0 表示使用默认值
 }
 
 // TableName 指定表名
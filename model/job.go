@@ -0,0 +1,35 @@
+package model
+
+import "time"
+
+// Job 表示在组节点上后台运行的命令
+// 每个节点上的进程脱离SSH连接运行，输出和退出码写入节点上的作业目录，之后可随时查询
+type Job struct {
+	ID        string    `gorm:"primaryKey"`
+	GroupName string    `gorm:"index"`
+	Command   string    `gorm:""`
+	StartTime time.Time `gorm:"index"`
+}
+
+// TableName 指定表名
+func (Job) TableName() string {
+	return "jobs"
+}
+
+// JobNode 表示作业在单个节点上的进程
+type JobNode struct {
+	ID        string    `gorm:"primaryKey"` // 作业ID-节点IP
+	JobID     string    `gorm:"index"`
+	NodeID    string    `gorm:""` // 保存的节点ID，用于查询时获取连接信息
+	NodeIP    string    `gorm:""`
+	Port      int       `gorm:""`
+	User      string    `gorm:""`
+	PID       int       `gorm:""`
+	State     string    `gorm:""` // 最近一次查询到的状态，见 logic/jobs
+	CheckedAt time.Time `gorm:""`
+}
+
+// TableName 指定表名
+func (JobNode) TableName() string {
+	return "job_nodes"
+}
@@ -4,6 +4,14 @@ import (
 	"time"
 )
 
+// 节点认证方式
+const (
+	AuthPassword = "password"
+	AuthKey      = "key"
+	// AuthAgent 只用于网关代理：使用转发的 SSH agent 和本机用户的默认私钥
+	AuthAgent = "agent"
+)
+
 // Node 表示集群中的一个节点
 type Node struct {
 	ID          string    `gorm:"primaryKey"`
@@ -11,11 +19,16 @@ type Node struct {
 	Port        int       `gorm:"default:22"`
 	User        string    `gorm:"default:root"`
 	Password    string    `gorm:""`
+	AuthType    string    `gorm:"default:password"` // password 或 key，key 时只使用私钥认证
+	KeyPath     string    `gorm:""`                 // 私钥路径，AuthType 为 key 时使用
+	JumpHosts   string    `gorm:""`                 // 逗号分隔的跳板机节点ID，为空时使用组的配置
 	Group       string    `gorm:"index"`
 	AddAt       time.Time `gorm:""`
 	LastCheckAt time.Time `gorm:""`
 	Usable      bool      `gorm:"default:false"`
 	Description string    `gorm:""`
+	Facts       string    `gorm:"type:text"` // facts 命令采集的节点信息（JSON），见 types.Facts
+	FactsAt     time.Time `gorm:""`          // 最近一次采集的时间
 }
 
 // TableName 指定表名
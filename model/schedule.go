@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// Schedule 表示按 cron 表达式或固定间隔在组上定期执行的命令
+// 由 daemon 命令运行，每次执行记录为一个会话，合并结果与上次不同时发送通知
+type Schedule struct {
+	Name      string `gorm:"primaryKey"`
+	GroupName string `gorm:"index"`
+	Commands  string `gorm:"type:text"` // 每行一条命令，在同一会话中依次执行
+	Cron      string `gorm:""`          // cron 表达式，与 Interval 二选一
+	Interval  int64  `gorm:""`          // 执行间隔（秒）
+	Timeout   int    `gorm:""`          // 每条命令的超时时间（秒）
+	Exclude   string `gorm:""`          // 排除的节点，支持范围表示法
+	Normalize bool   `gorm:"default:false"`
+
+	NotifyCommand string `gorm:""` // 结果变化时在本机执行的命令
+	NotifyWebhook string `gorm:""` // 结果变化时 POST 通知的地址
+
+	CreatedAt     time.Time `gorm:""`
+	LastRunAt     time.Time `gorm:""`
+	LastSessionID string    `gorm:""`          // 最近一次执行记录的会话ID
+	LastSummary   string    `gorm:"type:text"` // 最近一次执行的合并结果，用于判断结果是否变化
+	LastChangedAt time.Time `gorm:""`
+}
+
+// TableName 指定表名
+func (Schedule) TableName() string {
+	return "schedules"
+}
@@ -38,11 +38,15 @@ func (Command) TableName() string {
 
 // CommandOutput 表示命令在特定节点上的输出
 type CommandOutput struct {
-	ID        string `gorm:"primaryKey"`
-	CommandID string `gorm:"index"`
-	NodeIP    string `gorm:"index"`
-	Output    string `gorm:"type:text"`
-	ExitCode  int    `gorm:""`
+	ID         string `gorm:"primaryKey"`
+	CommandID  string `gorm:"index"`
+	NodeIP     string `gorm:"index"`
+	Output     string `gorm:"type:text"`
+	Stderr     string `gorm:"type:text"` // 不分配伪终端执行时单独捕获的标准错误
+	ExitCode   int    `gorm:""`
+	Signal     string `gorm:""`              // 远程进程被信号终止时的信号名
+	TimedOut   bool   `gorm:"default:false"` // 命令是否超时未结束
+	Compressed bool   `gorm:"default:false"` // Output 和 Stderr 是否为 gzip+base64 压缩后的内容
 }
 
 // TableName 指定表名